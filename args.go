@@ -0,0 +1,109 @@
+package qb
+
+import (
+	"reflect"
+)
+
+// A SharedArg is an argument which is passed once, however many times it is
+// used in a query, in the dialects with numbered placeholders.
+type SharedArg struct {
+	v interface{}
+}
+
+// Wraps a value to be used as an argument several times in a query, with a
+// single placeholder in the dialects with numbered placeholders (pq, mssql
+// and goracle):
+//  now := qb.Arg(time.Now())
+//  q := qb.WithDialectPQ().Select("*").From("t").
+//  	Where(qb.Pred("starts_at <= ? AND ends_at > ?", now, now))
+//  // SELECT * FROM t WHERE starts_at <= $1 AND ends_at > $1
+// In the other dialects, the value is passed for every use.
+func Arg(v interface{}) *SharedArg {
+	return &SharedArg{v}
+}
+
+func (a *SharedArg) Value() interface{} {
+	return a.v
+}
+
+func DedupArgs() Query {
+	return Query{}.DedupArgs()
+}
+
+// Passes identical arguments of the query, including in the queries nested in
+// it, only once in the dialects with numbered placeholders; every use of the
+// argument refers to the same placeholder. Arguments are identical if they
+// are equal with ==, so values of types which are not comparable, such as
+// slices, are always passed on their own. See Arg for sharing an argument
+// explicitly.
+func (q Query) DedupArgs() Query {
+	q.dedupArgs = true
+	return q
+}
+
+// numbered reports whether the placeholders of the dialect are numbered.
+func (d Dialect) numbered() bool {
+	switch d {
+	case DialectPq, DialectMssql, DialectGoracle:
+		return true
+	default:
+		return false
+	}
+}
+
+// numberArgs numbers the arguments of an expanded writer, and unwraps its
+// shared arguments. If numbered is true, every use of a shared argument is
+// given the same number, as is every use of an identical argument if dedup is
+// true, and only the first use is passed as an argument.
+func (q *sqlWriter) numberArgs(numbered, dedup bool) {
+	var n int
+	var seen map[interface{}]int
+	for i := range q.tokens {
+		t := &q.tokens[i]
+		if !t.isArg {
+			continue
+		}
+
+		key, share := t.arg, false
+		if a, ok := t.arg.(*SharedArg); ok {
+			t.arg, share = a.v, numbered
+		} else if dedup && numbered {
+			share = hashable(key)
+		}
+
+		if !share {
+			n++
+			t.n = n
+			continue
+		}
+
+		if m, ok := seen[key]; ok {
+			t.n, t.reused = m, true
+			continue
+		}
+
+		n++
+		t.n = n
+		if seen == nil {
+			seen = map[interface{}]int{}
+		}
+		seen[key] = n
+	}
+}
+
+// hashable reports whether v may be used as a map key. A comparable type is
+// not enough, as a struct such as ArrayValue may hold a slice in an interface
+// field, which panics when hashed.
+func hashable(v interface{}) (ok bool) {
+	if v != nil && !reflect.TypeOf(v).Comparable() {
+		return false
+	}
+
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = map[interface{}]bool{v: true}
+	return true
+}
@@ -0,0 +1,111 @@
+package qb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestArgs_Dedup(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	shared := qb.Arg(now)
+
+	tests := []struct {
+		name  string
+		expr  string
+		args  []interface{}
+		query func() qb.Query
+	}{
+		{
+			name: "shared pq",
+			expr: `SELECT * FROM t WHERE starts_at <= $1 AND ends_at > $1 AND id = $2`,
+			args: []interface{}{now, 1},
+			query: func() qb.Query {
+				return qb.WithDialectPQ().Select("*").From("t").
+					Where(qb.Pred("starts_at <= ? AND ends_at > ?", shared, shared)).
+					Where(qb.Pred("id = ?", 1))
+			},
+		},
+		{
+			name: "shared mysql",
+			expr: `SELECT * FROM t WHERE starts_at <= ? AND ends_at > ?`,
+			args: []interface{}{now, now},
+			query: func() qb.Query {
+				return qb.WithDialectMysql().Select("*").From("t").
+					Where(qb.Pred("starts_at <= ? AND ends_at > ?", shared, shared))
+			},
+		},
+		{
+			name: "without dedup",
+			expr: `SELECT * FROM t WHERE a = $1 AND b = $2`,
+			args: []interface{}{1, 1},
+			query: func() qb.Query {
+				return qb.WithDialectPQ().Select("*").From("t").Where(qb.Pred("a = ? AND b = ?", 1, 1))
+			},
+		},
+		{
+			name: "dedup",
+			expr: `SELECT * FROM t WHERE a = @p1 AND b = @p2 AND c = @p1 AND d IN ( SELECT d FROM u WHERE e = @p2 AND f = @p3 AND g = @p4 )`,
+			args: []interface{}{1, "x", []byte("y"), []byte("y")},
+			query: func() qb.Query {
+				return qb.WithDialectMssql().Select("*").From("t").
+					Where(qb.Pred("a = ? AND b = ? AND c = ?", 1, "x", 1)).
+					Where(qb.Pred("d IN ?", qb.Select("d").From("u").
+						Where(qb.Pred("e = ? AND f = ? AND g = ?", "x", []byte("y"), []byte("y"))))).
+					DedupArgs()
+			},
+		},
+		{
+			name: "dedup typed",
+			expr: `UPDATE t SET a = :1 , b = :2 , c = :1 , d = :3 , e = :3`,
+			args: []interface{}{1, int64(1), nil},
+			query: func() qb.Query {
+				return qb.WithDialectGoracle().Update("t").
+					Set("a = ?", 1).Set("b = ?", int64(1)).Set("c = ?", 1).
+					Set("d = ?", nil).Set("e = ?", nil).
+					DedupArgs()
+			},
+		},
+		{
+			name: "dedup arrays",
+			expr: `SELECT * FROM t WHERE a = ANY( $1 ) AND b = ANY( $2 ) AND c = $3 AND d = $3`,
+			args: []interface{}{qb.Array([]int{1, 2}), qb.Array([]int{1, 2}), 1},
+			query: func() qb.Query {
+				return qb.WithDialectPQ().Select("*").From("t").
+					Where(qb.Pred("a = ANY(?) AND b = ANY(?) AND c = ? AND d = ?", qb.Array([]int{1, 2}), qb.Array([]int{1, 2}), 1, 1)).
+					DedupArgs()
+			},
+		},
+		{
+			name: "dedup sqlite",
+			expr: `SELECT * FROM t WHERE a = ? AND b = ?`,
+			args: []interface{}{1, 1},
+			query: func() qb.Query {
+				return qb.WithDialectSqlite().Select("*").From("t").Where(qb.Pred("a = ? AND b = ?", 1, 1)).DedupArgs()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query()
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+
+	t.Run("template", func(t *testing.T) {
+		tmpl := qb.WithDialectPQ().Select("*").From("t").
+			Where(qb.Pred("a = ? OR b = ?", qb.Param("x"), qb.Param("x"))).
+			DedupArgs().
+			Template()
+
+		sql, args, err := tmpl.Bind(qb.Args{"x": 1})
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM t WHERE a = $1 OR b = $1`, sql)
+		require.Equal(t, []interface{}{1}, args)
+	})
+}
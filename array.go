@@ -0,0 +1,306 @@
+package qb
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArrayValue is a Go slice passed as a postgres array. It implements
+// driver.Valuer, so that drivers without array support of their own, such as
+// lib/pq, can pass it.
+type ArrayValue struct {
+	v interface{}
+}
+
+// Wraps a slice, or an array, to be passed as a postgres array argument:
+//  qb.Pred("tags && ?", qb.Array([]string{"a", "b"}))
+// Nested slices are passed as multidimensional arrays. Nil elements and nil
+// pointers are passed as NULL. A nil slice is passed as NULL.
+func Array(v interface{}) ArrayValue {
+	if a, ok := v.(ArrayValue); ok {
+		return a
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array || rv.Type().Elem().Kind() == reflect.Uint8 {
+		panic(fmt.Errorf("qb: Array of %T; a slice of elements other than bytes is required", v))
+	}
+	return ArrayValue{v}
+}
+
+func (a ArrayValue) Value() (driver.Value, error) {
+	rv := reflect.ValueOf(a.v)
+	if rv.Kind() == reflect.Slice && rv.IsNil() {
+		return nil, nil
+	}
+
+	b, err := appendArray(nil, rv)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Len returns the number of elements of the array.
+func (a ArrayValue) Len() int {
+	return reflect.ValueOf(a.v).Len()
+}
+
+// elems returns the elements of the array.
+func (a ArrayValue) elems() []interface{} {
+	rv := reflect.ValueOf(a.v)
+	elems := make([]interface{}, rv.Len())
+	for i := range elems {
+		elems[i] = rv.Index(i).Interface()
+	}
+	return elems
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// sqlType returns the postgres type of the array, or "" if it is not known.
+func (a ArrayValue) sqlType() string {
+	t := reflect.TypeOf(a.v).Elem()
+	dims := "[]"
+	for t.Kind() == reflect.Ptr || (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 {
+		if t.Kind() != reflect.Ptr {
+			dims += "[]"
+		}
+		t = t.Elem()
+	}
+
+	var elem string
+	switch {
+	case t == timeType:
+		elem = "timestamptz"
+	case t.Implements(valuerType):
+		return ""
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		elem = "bytea"
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			elem = "boolean"
+		case reflect.Int8, reflect.Int16, reflect.Uint8:
+			elem = "smallint"
+		case reflect.Int32, reflect.Uint16:
+			elem = "integer"
+		case reflect.Int, reflect.Int64, reflect.Uint32:
+			elem = "bigint"
+		case reflect.Float32:
+			elem = "real"
+		case reflect.Float64:
+			elem = "double precision"
+		case reflect.String:
+			elem = "text"
+		default:
+			return ""
+		}
+	}
+	return elem + dims
+}
+
+// appendArray appends the postgres array literal of a slice to b.
+func appendArray(b []byte, rv reflect.Value) ([]byte, error) {
+	b = append(b, '{')
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		var err error
+		if b, err = appendElem(b, rv.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+func appendElem(b []byte, rv reflect.Value) ([]byte, error) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return append(b, "NULL"...), nil
+		}
+		if v, ok := rv.Interface().(driver.Valuer); ok {
+			return appendValuer(b, v)
+		}
+		rv = rv.Elem()
+	}
+
+	if v, ok := rv.Interface().(driver.Valuer); ok {
+		return appendValuer(b, v)
+	}
+
+	switch v := rv.Interface().(type) {
+	case time.Time:
+		return appendQuoted(b, v.Format(time.RFC3339Nano)), nil
+	case []byte:
+		if v == nil {
+			return append(b, "NULL"...), nil
+		}
+		return appendQuoted(b, `\x`+hex.EncodeToString(v)), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return appendArray(b, rv)
+	case reflect.Bool:
+		if rv.Bool() {
+			return append(b, 't'), nil
+		}
+		return append(b, 'f'), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(b, rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.AppendUint(b, rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.AppendFloat(b, rv.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.AppendFloat(b, rv.Float(), 'g', -1, 64), nil
+	case reflect.String:
+		return appendQuoted(b, rv.String()), nil
+	default:
+		return nil, fmt.Errorf("qb: cannot pass %s in an array", rv.Type())
+	}
+}
+
+func appendValuer(b []byte, v driver.Valuer) ([]byte, error) {
+	dv, err := v.Value()
+	if err != nil {
+		return nil, err
+	}
+	if dv == nil {
+		return append(b, "NULL"...), nil
+	}
+	return appendElem(b, reflect.ValueOf(dv))
+}
+
+var arrayQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func appendQuoted(b []byte, s string) []byte {
+	b = append(b, '"')
+	b = append(b, arrayQuoter.Replace(s)...)
+	return append(b, '"')
+}
+
+//  column = ANY(?)
+// In the dialects other than pq, this is written as:
+//  column IN (?[, ?[, ...]])
+func (c Col) AnyOf(values interface{}) Predicate {
+	var pred Predicate
+	pred.count = 1
+	pred.w.WriteFragment(arrayAny{c, Array(values)})
+	return pred
+}
+
+//  column @> ?
+func (c Col) ArrayContains(values interface{}) Predicate {
+	var pred Predicate
+	pred.count = 1
+	pred.w.WriteFragment(arrayOp{c, "@>", Array(values)})
+	return pred
+}
+
+//  column && ?
+func (c Col) ArrayOverlaps(values interface{}) Predicate {
+	var pred Predicate
+	pred.count = 1
+	pred.w.WriteFragment(arrayOp{c, "&&", Array(values)})
+	return pred
+}
+
+//  array_length(column, 1)
+func (c Col) ArrayLength() string {
+	return "array_length(" + string(c) + ", 1)"
+}
+
+// isPostgres reports whether the dialect has postgres arrays.
+func isPostgres(d Dialect) bool {
+	return d == DialectDefault || d == DialectPq
+}
+
+type arrayAny struct {
+	c Col
+	a ArrayValue
+}
+
+func (f arrayAny) writeTo(w *sqlWriter, d Dialect) error {
+	if isPostgres(d) {
+		w.WriteSQL(string(f.c) + " = ANY(")
+		w.WriteArg(f.a)
+		w.WriteSQL(")")
+		return nil
+	}
+
+	p := f.c.In(f.a.elems()...)
+	w.Append(&p.w)
+	return nil
+}
+
+type arrayOp struct {
+	c  Col
+	op string
+	a  ArrayValue
+}
+
+func (f arrayOp) writeTo(w *sqlWriter, d Dialect) error {
+	if !isPostgres(d) {
+		return fmt.Errorf("qb: array operator %s is not supported in the %s dialect", f.op, dialectName(d))
+	}
+
+	w.WriteSQL(string(f.c), f.op)
+	w.WriteArg(f.a)
+	return nil
+}
+
+// Appends a SELECT statement which returns the rows of the given arrays, one
+// element of each array per row. As the source of an INSERT statement, it
+// inserts many rows with one argument per column:
+//  qb.InsertInto("t", "id", "name").
+//  	SelectUnnest([]int64{1, 2}, []string{"a", "b"})
+//  // INSERT INTO t ( id , name ) SELECT * FROM unnest( $1 ::bigint[] , $2 ::text[] )
+// The arrays are cast to the postgres types of their elements, where known.
+// This is supported in the pq dialect.
+func (q Query) SelectUnnest(arrays ...interface{}) Query {
+	as := make([]ArrayValue, len(arrays))
+	for i, a := range arrays {
+		as[i] = Array(a)
+	}
+
+	q.last = fromExpr
+	q.w.WriteSQL("SELECT", "*", "FROM")
+	q.w.WriteFragment(unnest{as})
+	return q
+}
+
+type unnest struct {
+	arrays []ArrayValue
+}
+
+func (u unnest) writeTo(w *sqlWriter, d Dialect) error {
+	if !isPostgres(d) {
+		return fmt.Errorf("qb: unnest is not supported in the %s dialect", dialectName(d))
+	}
+
+	w.WriteSQL("unnest(")
+	for i, a := range u.arrays {
+		if i > 0 {
+			w.WriteSQL(",")
+		}
+
+		w.WriteArg(a)
+		if t := a.sqlType(); t != "" {
+			w.WriteSQL("::" + t)
+		}
+	}
+	w.WriteSQL(")")
+	return nil
+}
@@ -0,0 +1,91 @@
+package qb_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestArray_Value(t *testing.T) {
+	s := "x"
+	tests := []struct {
+		array interface{}
+		value interface{}
+	}{
+		{[]int{1, -2, 3}, `{1,-2,3}`},
+		{[]string{"a", `b "c"`, `d\e`, "", "NULL"}, `{"a","b \"c\"","d\\e","","NULL"}`},
+		{[]*string{&s, nil}, `{"x",NULL}`},
+		{[]interface{}{1, "a", nil, true}, `{1,"a",NULL,t}`},
+		{[][]float64{{1.5, 2}, {3, 4}}, `{{1.5,2},{3,4}}`},
+		{[]bool{true, false}, `{t,f}`},
+		{[][]byte{{0xde, 0xad}, nil}, `{"\\xdead",NULL}`},
+		{[]time.Time{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}, `{"2020-01-02T03:04:05Z"}`},
+		{[]sql.NullInt64{{Int64: 1, Valid: true}, {}}, `{1,NULL}`},
+		{[3]uint{1, 2, 3}, `{1,2,3}`},
+		{[]int{}, `{}`},
+		{[]int(nil), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.array), func(t *testing.T) {
+			v, err := qb.Array(tt.array).Value()
+			require.NoError(t, err)
+			require.Equal(t, tt.value, v)
+		})
+	}
+
+	_, err := qb.Array([]struct{}{{}}).Value()
+	require.EqualError(t, err, "qb: cannot pass struct {} in an array")
+
+	require.Panics(t, func() { qb.Array("abc") })
+	require.Panics(t, func() { qb.Array([]byte("abc")) })
+}
+
+func TestArray_Predicates(t *testing.T) {
+	tags := qb.Col("tags")
+	q := qb.WithDialectPQ().Select("*").From("posts").
+		Where(qb.Col("id").AnyOf([]int64{1, 2})).
+		Where(tags.ArrayContains([]string{"go"})).
+		Where(tags.ArrayOverlaps([]string{"sql", "db"})).
+		Where(qb.Pred(tags.ArrayLength()+" > ?", 2))
+
+	require.Equal(t, `SELECT * FROM posts WHERE id = ANY( $1 ) AND tags @> $2 AND tags && $3 AND array_length(tags, 1) > $4`, q.SQL())
+	require.Equal(t, []interface{}{
+		qb.Array([]int64{1, 2}),
+		qb.Array([]string{"go"}),
+		qb.Array([]string{"sql", "db"}),
+		2,
+	}, q.Args())
+
+	t.Run("any in other dialects", func(t *testing.T) {
+		q := qb.WithDialectMysql().Select("*").From("posts").Where(qb.Col("id").AnyOf([]int64{1, 2}))
+		require.Equal(t, `SELECT * FROM posts WHERE id IN ( ? , ? )`, q.SQL())
+		require.Equal(t, []interface{}{int64(1), int64(2)}, q.Args())
+
+		q = qb.WithDialectMssql().Select("*").From("posts").Where(qb.Col("id").AnyOf([]int64{}))
+		require.Equal(t, `SELECT * FROM posts WHERE 1 = 0`, q.SQL())
+	})
+
+	t.Run("operators in other dialects", func(t *testing.T) {
+		q := qb.WithDialectMysql().Select("*").From("posts").Where(tags.ArrayOverlaps([]string{"a"}))
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: array operator && is not supported in the mysql dialect")
+	})
+}
+
+func TestQuery_SelectUnnest(t *testing.T) {
+	q := qb.WithDialectPQ().
+		InsertInto("t", "id", "name", "at").
+		SelectUnnest([]int64{1, 2}, []string{"a", "b"}, []*time.Time{nil, nil}).
+		Returning("id")
+
+	require.Equal(t, `INSERT INTO t ( id , name , at ) SELECT * FROM unnest( $1 ::bigint[] , $2 ::text[] , $3 ::timestamptz[] ) RETURNING id`, q.SQL())
+	require.Len(t, q.Args(), 3)
+
+	q = q.DialectOption(qb.DialectSqlite)
+	require.EqualError(t, recoverError(func() { q.SQL() }), "qb: unnest is not supported in the sqlite dialect")
+}
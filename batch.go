@@ -0,0 +1,97 @@
+package qb
+
+// Splits a query with multiple statements, such as one created by Multiple or
+// parsed from several statements, into its statements. Each statement is
+// rendered on its own, with its placeholders numbered from the first:
+//  qb.Multiple(q0, q1).Statements()[1].SQL() // ... $1 ...
+// The dialect, scopes and modes of the query apply to each statement. A query
+// with a single statement is returned as is, except for a Begin which is
+// rendered as two statements in the dialect.
+func (q Query) Statements() []Query {
+	if !q.isMultiple() {
+		if b, ok := q.w.lastFragment().(beginTransaction); ok && q.w.Len() == 1 {
+			return b.statements(q)
+		}
+		return []Query{q}
+	}
+
+	var qs []Query
+	for _, t := range q.w.tokens {
+		n, ok := t.frag.(nestedQuery)
+		if !ok {
+			continue
+		}
+
+		sq := n.q
+		sq.Dialect = q.Dialect
+		sq.strict = sq.strict || q.strict
+		sq.unscoped = sq.unscoped || q.unscoped
+		sq.hardDelete = sq.hardDelete || q.hardDelete
+		sq.dedupArgs = sq.dedupArgs || q.dedupArgs
+		if sq.deleted == deletedDefault {
+			sq.deleted = q.deleted
+		}
+		if len(q.scopes) > 0 {
+			scopes1 := make([]tableScope, 0, len(q.scopes)+len(sq.scopes))
+			scopes1 = append(scopes1, q.scopes...)
+			sq.scopes = append(scopes1, sq.scopes...)
+		}
+
+		qs = append(qs, sq.Statements()...)
+	}
+	return qs
+}
+
+// isMultiple reports whether the query consists of statements separated by
+// semicolons, as written by Multiple.
+func (q Query) isMultiple() bool {
+	if q.w.Len() == 0 || q.w.Len()%2 != 0 {
+		return false
+	}
+
+	for i, t := range q.w.tokens {
+		if i%2 == 1 {
+			if t.frag != nil || t.sql != ";" {
+				return false
+			}
+		} else if n, ok := t.frag.(nestedQuery); !ok || n.parens {
+			return false
+		}
+	}
+	return true
+}
+
+// A BatchQuery is a statement of a batch, with its own arguments.
+type BatchQuery struct {
+	SQL  string
+	Args []interface{}
+}
+
+// Renders the statements of the query separately, for drivers which send a
+// batch of statements in one round-trip but take the arguments of each
+// statement on its own, such as pgx:
+//  batch := &pgx.Batch{}
+//  for _, bq := range q.Batch() {
+//  	batch.Queue(bq.SQL, bq.Args...)
+//  }
+func (q Query) Batch() []BatchQuery {
+	batch, err := q.TryBatch()
+	if err != nil {
+		panic(err)
+	}
+	return batch
+}
+
+// Renders the statements of the query like Batch, or returns the error of the
+// first statement which cannot be rendered.
+func (q Query) TryBatch() ([]BatchQuery, error) {
+	qs := q.Statements()
+	batch := make([]BatchQuery, len(qs))
+	for i, sq := range qs {
+		var err error
+		if batch[i].SQL, batch[i].Args, err = sq.TryBuild(); err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,62 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestQuery_Statements(t *testing.T) {
+	q := qb.Multiple(
+		qb.Update("t1").Set("a = ?", 1).Where(qb.Pred("id = ?", 2)),
+		qb.Multiple(
+			qb.DeleteFrom("t2").Where(qb.Pred("id = ?", 3)),
+			qb.Select("*").From("t3"),
+		),
+	).DialectOption(qb.DialectPq)
+
+	require.Equal(t, `UPDATE t1 SET a = $1 WHERE id = $2 ; DELETE FROM t2 WHERE id = $3 ; SELECT * FROM t3 ; ;`, q.SQL())
+
+	qs := q.Statements()
+	require.Len(t, qs, 3)
+	require.Equal(t, `UPDATE t1 SET a = $1 WHERE id = $2`, qs[0].SQL())
+	require.Equal(t, []interface{}{1, 2}, qs[0].Args())
+	require.Equal(t, `DELETE FROM t2 WHERE id = $1`, qs[1].SQL())
+	require.Equal(t, []interface{}{3}, qs[1].Args())
+	require.Equal(t, `SELECT * FROM t3`, qs[2].SQL())
+
+	require.Equal(t, []qb.BatchQuery{
+		{SQL: `UPDATE t1 SET a = $1 WHERE id = $2`, Args: []interface{}{1, 2}},
+		{SQL: `DELETE FROM t2 WHERE id = $1`, Args: []interface{}{3}},
+		{SQL: `SELECT * FROM t3`, Args: []interface{}{}},
+	}, q.Batch())
+
+	t.Run("single statement", func(t *testing.T) {
+		q := qb.Select("*").From("t1")
+		require.Equal(t, []qb.Query{q}, q.Statements())
+	})
+
+	t.Run("parsed", func(t *testing.T) {
+		q, err := qb.Parse(`SELECT * FROM t1 WHERE a = $1; SELECT * FROM t2 WHERE b = $2`, qb.DialectPq)
+		require.NoError(t, err)
+
+		batch := q.WithArgs(1, 2).Batch()
+		require.Equal(t, []qb.BatchQuery{
+			{SQL: `SELECT * FROM t1 WHERE a = $1`, Args: []interface{}{1}},
+			{SQL: `SELECT * FROM t2 WHERE b = $1`, Args: []interface{}{2}},
+		}, batch)
+	})
+
+	t.Run("scopes", func(t *testing.T) {
+		q := qb.Multiple(qb.Select("*").From("orders")).
+			WithScope("orders", func(ref qb.TableRef) qb.Predicate {
+				return qb.Pred(ref.Name()+".tenant_id = ?", 7)
+			})
+
+		qs := q.Statements()
+		require.Equal(t, `SELECT * FROM orders WHERE orders.tenant_id = ?`, qs[0].SQL())
+		require.Equal(t, []interface{}{7}, qs[0].Args())
+	})
+}
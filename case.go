@@ -0,0 +1,122 @@
+package qb
+
+import (
+	"fmt"
+)
+
+// CaseExpr is a CASE expression. It is passed as an argument wherever
+// expressions take arguments, such as to SelectColumn, Set and Pred, and to
+// OrderByExpr:
+//  tier := qb.Case().
+//  	When(qb.Col("total").Ge(1000), "gold").
+//  	When(qb.Col("total").Ge(100), "silver").
+//  	Else("bronze")
+//  q := qb.Select("id").SelectColumn(`? AS "tier"`, tier).From("customers")
+//  // SELECT id , CASE WHEN total >= ? THEN ? WHEN total >= ? THEN ? ELSE ? END AS "tier" FROM customers
+// Columns are passed as Col, and other values and results are written the
+// same way as arguments to Pred: Lit values are written as SQL, queries as
+// subqueries, and other values are passed as arguments.
+type CaseExpr struct {
+	w      sqlWriter
+	els    sqlWriter
+	simple bool
+	whens  int
+}
+
+// Returns a searched CASE expression, whose conditions are predicates:
+//  CASE WHEN predicate THEN result ... END
+// or, given an expression, a simple CASE expression, whose conditions are
+// values compared with the expression:
+//  CASE expr WHEN value THEN result ... END
+func Case(expr ...string) CaseExpr {
+	if len(expr) > 1 {
+		panic(fmt.Errorf("qb: Case of %d expressions; at most one is allowed", len(expr)))
+	}
+
+	var c CaseExpr
+	c.w.WriteSQL("CASE")
+	if len(expr) == 1 {
+		c.simple = true
+		c.w.writeChecked(checkColumn, expr[0])
+	}
+	return c
+}
+
+// Appends a WHEN clause. The condition of a searched CASE expression is a
+// Predicate, and that of a simple CASE expression is a value.
+//  WHEN condition THEN result
+func (c CaseExpr) When(cond interface{}, result interface{}) CaseExpr {
+	c.w.WriteSQL("WHEN")
+	if p, ok := cond.(Predicate); ok {
+		if c.simple {
+			panic(fmt.Errorf("qb: When of a simple CASE expression takes a value, not a Predicate"))
+		}
+		c.w.Append(&p.w)
+	} else {
+		if !c.simple {
+			panic(fmt.Errorf("qb: When of a searched CASE expression takes a Predicate, not %T", cond))
+		}
+		c.w.writeOperand(cond)
+	}
+
+	c.w.WriteSQL("THEN")
+	c.w.writeOperand(result)
+	c.whens++
+	return c
+}
+
+// Sets the result of the expression when no condition holds, which is NULL
+// otherwise.
+//  ELSE result
+func (c CaseExpr) Else(result interface{}) CaseExpr {
+	c.els = sqlWriter{}
+	c.els.WriteSQL("ELSE")
+	c.els.writeOperand(result)
+	return c
+}
+
+func (c CaseExpr) String() string {
+	var w sqlWriter
+	w.WriteFragment(c)
+	return w.String()
+}
+
+func (c CaseExpr) writeTo(w *sqlWriter, d Dialect) error {
+	if c.whens == 0 {
+		return fmt.Errorf("qb: CASE expression without WHEN")
+	}
+
+	w.Append(&c.w)
+	w.Append(&c.els)
+	w.WriteSQL("END")
+	return nil
+}
+
+func (c CaseExpr) mapArgs(f func(v interface{}) interface{}) fragment {
+	c.w = c.w.mapArgs(f)
+	c.els = c.els.mapArgs(f)
+	return c
+}
+
+func (c CaseExpr) mapQueries(f func(q Query) Query) fragment {
+	c.w = c.w.mapQueries(f)
+	c.els = c.els.mapQueries(f)
+	return c
+}
+
+// Appends an ORDER BY term, or, after an ORDER BY clause, another term, in
+// which each ? stands for the next argument, such as a CASE expression:
+//  q.OrderByExpr("?", qb.Case().When(qb.Col("pinned").Eq(true), 0).Else(1))
+//  // ... ORDER BY CASE WHEN pinned = ? THEN ? ELSE ? END
+func (q Query) OrderByExpr(expr string, args ...interface{}) Query {
+	prefix := "ORDER BY"
+	if q.last == orderByExpr {
+		prefix = ","
+	}
+
+	q.last = orderByExpr
+	q.ordered = true
+	q.w.WriteSQL(prefix)
+	q.w.WriteExpr(expr, args...)
+	return q
+}
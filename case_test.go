@@ -0,0 +1,106 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestCase(t *testing.T) {
+	total := qb.Col("total")
+	tier := qb.Case().
+		When(total.Ge(1000), "gold").
+		When(total.Ge(100).And("active = ?", true), qb.Lit("'silver'")).
+		Else(qb.NULL)
+
+	tests := []struct {
+		name string
+		q    qb.Query
+		expr string
+		args []interface{}
+	}{
+		{
+			name: "select",
+			q:    qb.WithDialectPQ().Select("id").SelectColumn(`? AS "tier"`, tier).From("customers"),
+			expr: `SELECT id ,  CASE WHEN total >= $1 THEN $2 WHEN total >= $3 AND active = $4 THEN 'silver' ELSE NULL END  AS "tier" FROM customers`,
+			args: []interface{}{1000, "gold", 100, true},
+		},
+		{
+			name: "simple",
+			q: qb.Update("orders").
+				Set("status = ?", qb.Case("code").When(1, "new").When(2, "paid")).
+				Where(qb.Pred("id = ?", 7)),
+			expr: `UPDATE orders SET status = CASE code WHEN ? THEN ? WHEN ? THEN ? END WHERE id = ?`,
+			args: []interface{}{1, "new", 2, "paid", 7},
+		},
+		{
+			name: "subquery",
+			q: qb.Select("id").
+				SelectColumn("? AS n", qb.Case().
+					When(qb.Col("kind").Eq("team"), qb.Select("count(*)").From("members").Where(qb.Pred("team_id = ?", 3))).
+					Else(0)).
+				From("accounts"),
+			expr: `SELECT id ,  CASE WHEN kind = ? THEN ( SELECT count(*) FROM members WHERE team_id = ? ) ELSE ? END  AS n FROM accounts`,
+			args: []interface{}{"team", 3, 0},
+		},
+		{
+			name: "order by",
+			q: qb.Select("*").From("posts").
+				OrderByExpr("?", qb.Case().When(qb.Col("pinned").Eq(true), 0).Else(1)).
+				OrderByExpr("created_at DESC"),
+			expr: `SELECT * FROM posts ORDER BY  CASE WHEN pinned = ? THEN ? ELSE ? END , created_at DESC`,
+			args: []interface{}{true, 0, 1},
+		},
+		{
+			name: "nested",
+			q:    qb.Select("*").From("t").Where(qb.Pred("? = 'x'", qb.Case("a").When(1, qb.Case().When(qb.Col("b").IsNull(), "y").Else("x")))),
+			expr: `SELECT * FROM t WHERE  CASE a WHEN ? THEN CASE WHEN b IS NULL THEN ? ELSE ? END END  = 'x'`,
+			args: []interface{}{1, "y", "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expr, tt.q.SQL())
+			require.Equal(t, tt.args, tt.q.Args())
+		})
+	}
+
+	t.Run("reuse", func(t *testing.T) {
+		base := qb.Case("code").When(1, "a")
+		c1 := base.When(2, "b")
+		c2 := base.Else("c")
+		require.Equal(t, `CASE code WHEN ? THEN ? WHEN ? THEN ? END`, c1.String())
+		require.Equal(t, `CASE code WHEN ? THEN ? ELSE ? END`, c2.String())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		q := qb.SelectColumn("?", qb.Case().Else(1))
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: CASE expression without WHEN")
+		require.Panics(t, func() { qb.Case().When(1, 2) })
+		require.Panics(t, func() { qb.Case("a").When(qb.Col("b").IsNull(), 2) })
+		require.Panics(t, func() { qb.Case("a", "b") })
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		q := qb.Strict().SelectColumn("?", qb.Case("a; DROP TABLE t").When(1, 2)).From("t")
+		require.EqualError(t, recoverError(func() { q.SQL() }), `qb: strict: "a; DROP TABLE t" is not a valid column; use qb.Raw to write raw SQL`)
+
+		q = qb.Strict().SelectColumn("?", qb.Case().When(qb.Col("a").Eq(1), qb.Select("x").From("t; --"))).From("t")
+		require.Error(t, recoverError(func() { q.SQL() }))
+
+		q = qb.Strict().SelectColumn("?", qb.Case().When(qb.Col("a").Eq(1), qb.Col("b; DROP TABLE t"))).From("t")
+		require.EqualError(t, recoverError(func() { q.SQL() }), `qb: strict: "b; DROP TABLE t" is not a valid column; use qb.Raw to write raw SQL`)
+
+		q = qb.Strict().SelectColumn("?", qb.Case("a").When(qb.Col("b; --"), 1).Else(qb.Col("c"))).From("t")
+		require.EqualError(t, recoverError(func() { q.SQL() }), `qb: strict: "b; --" is not a valid column; use qb.Raw to write raw SQL`)
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		q := qb.Select("id").SelectColumn("?", qb.Case().When(qb.Col("kind").Eq(1), qb.Select("name").From("teams"))).From("accounts")
+		require.Equal(t, []qb.TableRef{{Table: "accounts"}, {Table: "teams"}}, q.Tables())
+		require.Contains(t, q.Columns(), "kind")
+	})
+}
@@ -0,0 +1,76 @@
+// Command qbgen generates Go packages describing database tables and columns
+// for use with qb, from the CREATE TABLE statements in SQL DDL files:
+//  qbgen -out ./internal/schema migrations/*.sql
+//
+// The files are read in order, and the ALTER TABLE and DROP TABLE statements
+// in them are applied to the tables created before.
+//
+// A package is written for every table, e.g. ./internal/schema/users, which
+// declares the table name as users.Table and every column as a qb.Column of
+// its Go type, such as users.Email, a qb.Column[string].
+//
+// qbgen links no database drivers. To generate packages from the
+// information_schema of a database, call qbgen.LoadInformationSchema and
+// qbgen.Generate from a program which imports the driver.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tetratom/qb/qbgen"
+)
+
+func main() {
+	out := flag.String("out", ".", "directory in which to write the packages")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: qbgen [-out dir] file.sql...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*out, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "qbgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(out string, paths []string) error {
+	var schema qbgen.Schema
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := schema.ApplyDDL(string(b)); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	files, err := qbgen.Generate(schema)
+	if err != nil {
+		return err
+	}
+
+	for name, src := range files {
+		path := filepath.Join(out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package qb
+
+// Col is the name of a column. It builds the common predicates on the column,
+// whose column name is checked by strict queries, and can be used wherever a
+// column name is taken as a string.
+type Col string
+
+func (c Col) String() string {
+	return string(c)
+}
+
+// Qualifies the column with a table name or alias.
+//  table.column
+func (c Col) Of(table string) Col {
+	return Col(table + "." + string(c))
+}
+
+//  column AS "alias"
+func (c Col) As(alias string) string {
+	return As(string(c), alias)
+}
+
+//  column ASC
+func (c Col) Asc() string {
+	return string(c) + " ASC"
+}
+
+//  column DESC
+func (c Col) Desc() string {
+	return string(c) + " DESC"
+}
+
+//  column = ?
+func (c Col) Eq(v interface{}) Predicate {
+	return c.pred("= ?", v)
+}
+
+//  column <> ?
+func (c Col) Ne(v interface{}) Predicate {
+	return c.pred("<> ?", v)
+}
+
+//  column < ?
+func (c Col) Lt(v interface{}) Predicate {
+	return c.pred("< ?", v)
+}
+
+//  column <= ?
+func (c Col) Le(v interface{}) Predicate {
+	return c.pred("<= ?", v)
+}
+
+//  column > ?
+func (c Col) Gt(v interface{}) Predicate {
+	return c.pred("> ?", v)
+}
+
+//  column >= ?
+func (c Col) Ge(v interface{}) Predicate {
+	return c.pred(">= ?", v)
+}
+
+//  column LIKE ?
+func (c Col) Like(pattern interface{}) Predicate {
+	return c.pred("LIKE ?", pattern)
+}
+
+//  column IN (?[, ?[, ...]])
+func (c Col) In(vs ...interface{}) Predicate {
+	if len(vs) == 0 {
+		return Pred("1 = 0")
+	}
+
+	expr := "IN (?"
+	for range vs[1:] {
+		expr += ", ?"
+	}
+	return c.pred(expr+")", vs...)
+}
+
+//  column IS NULL
+func (c Col) IsNull() Predicate {
+	return c.pred("IS NULL")
+}
+
+//  column IS NOT NULL
+func (c Col) IsNotNull() Predicate {
+	return c.pred("IS NOT NULL")
+}
+
+// pred returns the predicate of the column followed by an expression, in
+// which the column is checked as a column name by strict queries.
+func (c Col) pred(expr string, args ...interface{}) Predicate {
+	var p Predicate
+	p.count = 1
+	p.w.writeChecked(checkColumn, string(c))
+	p.w.WriteExpr(expr, args...)
+	return p
+}
+
+// Cols converts columns to strings for use in Select, OrderBy and GroupBy.
+func Cols(cols ...Col) []string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = string(c)
+	}
+	return names
+}
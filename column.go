@@ -0,0 +1,189 @@
+package qb
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// Column is a column whose values are of type T. Its predicates only accept
+// values of type T, and it is lowered to the same SQL as a Col.
+//
+// A nullable column has a pointer type, e.g. Column[*string], and its values
+// scan into a nil pointer for NULL. Eq and Ne compare such a column with the
+// value pointed to, or test it for NULL when passed a nil pointer.
+type Column[T any] struct {
+	col Col
+}
+
+func NewColumn[T any](name string) Column[T] {
+	return Column[T]{col: Col(name)}
+}
+
+func (c Column[T]) Col() Col {
+	return c.col
+}
+
+func (c Column[T]) String() string {
+	return string(c.col)
+}
+
+// Qualifies the column with a table name or alias.
+//  table.column
+func (c Column[T]) Of(table string) Column[T] {
+	return Column[T]{col: c.col.Of(table)}
+}
+
+func (c Column[T]) Asc() string          { return c.col.Asc() }
+func (c Column[T]) Desc() string         { return c.col.Desc() }
+func (c Column[T]) Lt(v T) Predicate     { return c.col.Lt(deref(v)) }
+func (c Column[T]) Le(v T) Predicate     { return c.col.Le(deref(v)) }
+func (c Column[T]) Gt(v T) Predicate     { return c.col.Gt(deref(v)) }
+func (c Column[T]) Ge(v T) Predicate     { return c.col.Ge(deref(v)) }
+func (c Column[T]) IsNull() Predicate    { return c.col.IsNull() }
+func (c Column[T]) IsNotNull() Predicate { return c.col.IsNotNull() }
+
+//  column = ?
+//  column IS NULL (for a nil pointer)
+func (c Column[T]) Eq(v T) Predicate {
+	if isNil(v) {
+		return c.col.IsNull()
+	}
+	return c.col.Eq(deref(v))
+}
+
+//  column <> ?
+//  column IS NOT NULL (for a nil pointer)
+func (c Column[T]) Ne(v T) Predicate {
+	if isNil(v) {
+		return c.col.IsNotNull()
+	}
+	return c.col.Ne(deref(v))
+}
+
+//  column IN (?[, ?[, ...]])
+func (c Column[T]) In(vs ...T) Predicate {
+	args := make([]interface{}, len(vs))
+	for i, v := range vs {
+		args[i] = deref(v)
+	}
+	return c.col.In(args...)
+}
+
+//  column = other
+func (c Column[T]) EqCol(other Column[T]) Predicate {
+	p := c.col.pred("=")
+	p.w.writeChecked(checkColumn, string(other.col))
+	return p
+}
+
+// isNil reports whether v is a nil pointer.
+func isNil(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// deref returns the value pointed to by v if it is a non-nil pointer.
+func deref(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return rv.Elem().Interface()
+	}
+	return v
+}
+
+// A Selection is a SELECT query whose rows scan into values of type R.
+type Selection[R any] struct {
+	Query Query
+	dests func(r *R) []interface{}
+}
+
+// Map applies f to the underlying query, e.g. to add FROM and WHERE clauses.
+func (s Selection[R]) Map(f func(q Query) Query) Selection[R] {
+	s.Query = f(s.Query)
+	return s
+}
+
+// A Scanner is a *sql.Row or *sql.Rows.
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scan scans the current row.
+func (s Selection[R]) Scan(row Scanner) (R, error) {
+	var r R
+	err := row.Scan(s.dests(&r)...)
+	return r, err
+}
+
+// ScanAll scans and closes rows.
+func (s Selection[R]) ScanAll(rows *sql.Rows) ([]R, error) {
+	defer rows.Close()
+
+	var rs []R
+	for rows.Next() {
+		r, err := s.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, rows.Err()
+}
+
+type Row2[A, B any] struct {
+	V1 A
+	V2 B
+}
+
+type Row3[A, B, C any] struct {
+	V1 A
+	V2 B
+	V3 C
+}
+
+type Row4[A, B, C, D any] struct {
+	V1 A
+	V2 B
+	V3 C
+	V4 D
+}
+
+//  SELECT c1
+func Select1[A any](c1 Column[A]) Selection[A] {
+	return Selection[A]{
+		Query: Select(c1.String()),
+		dests: func(r *A) []interface{} {
+			return []interface{}{r}
+		},
+	}
+}
+
+//  SELECT c1 , c2
+func Select2[A, B any](c1 Column[A], c2 Column[B]) Selection[Row2[A, B]] {
+	return Selection[Row2[A, B]]{
+		Query: Select(c1.String(), c2.String()),
+		dests: func(r *Row2[A, B]) []interface{} {
+			return []interface{}{&r.V1, &r.V2}
+		},
+	}
+}
+
+//  SELECT c1 , c2 , c3
+func Select3[A, B, C any](c1 Column[A], c2 Column[B], c3 Column[C]) Selection[Row3[A, B, C]] {
+	return Selection[Row3[A, B, C]]{
+		Query: Select(c1.String(), c2.String(), c3.String()),
+		dests: func(r *Row3[A, B, C]) []interface{} {
+			return []interface{}{&r.V1, &r.V2, &r.V3}
+		},
+	}
+}
+
+//  SELECT c1 , c2 , c3 , c4
+func Select4[A, B, C, D any](c1 Column[A], c2 Column[B], c3 Column[C], c4 Column[D]) Selection[Row4[A, B, C, D]] {
+	return Selection[Row4[A, B, C, D]]{
+		Query: Select(c1.String(), c2.String(), c3.String(), c4.String()),
+		dests: func(r *Row4[A, B, C, D]) []interface{} {
+			return []interface{}{&r.V1, &r.V2, &r.V3, &r.V4}
+		},
+	}
+}
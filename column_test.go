@@ -0,0 +1,73 @@
+package qb_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+var users = struct {
+	ID        qb.Column[int64]
+	Age       qb.Column[int]
+	Email     qb.Column[string]
+	Nickname  qb.Column[*string]
+	DeletedAt qb.Column[*int64]
+}{
+	ID:        qb.NewColumn[int64]("id"),
+	Age:       qb.NewColumn[int]("age"),
+	Email:     qb.NewColumn[string]("email"),
+	Nickname:  qb.NewColumn[*string]("nickname"),
+	DeletedAt: qb.NewColumn[*int64]("deleted_at"),
+}
+
+func TestColumn(t *testing.T) {
+	nickname := "bob"
+	q := qb.Select("*").From("users").
+		Where(users.Age.Gt(18)).
+		Where(users.Email.In("a@example.com", "b@example.com")).
+		Where(users.Nickname.Eq(&nickname)).
+		Where(users.DeletedAt.Eq(nil)).
+		OrderBy(users.ID.Of("users").Desc())
+
+	require.Equal(t, `SELECT * FROM users WHERE age > ? AND email IN ( ? , ? ) AND nickname = ? AND deleted_at IS NULL ORDER BY users.id DESC`, q.SQL())
+	require.Equal(t, []interface{}{18, "a@example.com", "b@example.com", "bob"}, q.Args())
+}
+
+func TestSelection(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{rows: [][]driver.Value{
+		{int64(1), "a@example.com", "al"},
+		{int64(2), "b@example.com", nil},
+	}}
+	db := sql.OpenDB(conn)
+
+	sel := qb.Select3(users.ID, users.Email, users.Nickname).
+		Map(func(q qb.Query) qb.Query {
+			return q.From("users").Where(users.Age.Ge(18))
+		})
+	require.Equal(t, `SELECT id , email , nickname FROM users WHERE age >= ?`, sel.Query.SQL())
+
+	rows, err := db.QueryContext(ctx, sel.Query.SQL(), sel.Query.Args()...)
+	require.NoError(t, err)
+
+	rs, err := sel.ScanAll(rows)
+	require.NoError(t, err)
+	require.Len(t, rs, 2)
+
+	require.Equal(t, int64(1), rs[0].V1)
+	require.Equal(t, "a@example.com", rs[0].V2)
+	require.Equal(t, "al", *rs[0].V3)
+	require.Equal(t, int64(2), rs[1].V1)
+	require.Nil(t, rs[1].V3)
+
+	conn.rows = [][]driver.Value{{int64(3)}}
+	one := qb.Select1(users.ID).Map(func(q qb.Query) qb.Query { return q.From("users") })
+	id, err := one.Scan(db.QueryRowContext(ctx, one.Query.SQL()))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), id)
+}
@@ -0,0 +1,454 @@
+package qb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A ColumnType is the type of a column in a table definition. Its name is
+// rendered according to the dialect.
+type ColumnType struct {
+	names map[Dialect]string
+	name  string
+}
+
+func (t ColumnType) nameIn(d Dialect) string {
+	if name, ok := t.names[d]; ok {
+		return name
+	}
+	return t.name
+}
+
+func columnType(name, mysql, mssql, goracle, sqlite string) ColumnType {
+	return ColumnType{
+		name: name,
+		names: map[Dialect]string{
+			DialectMysql:   mysql,
+			DialectMssql:   mssql,
+			DialectGoracle: goracle,
+			DialectSqlite:  sqlite,
+		},
+	}
+}
+
+var (
+	TypeBigSerial   = columnType("BIGSERIAL", "BIGINT AUTO_INCREMENT", "BIGINT IDENTITY(1,1)", "NUMBER(19) GENERATED BY DEFAULT AS IDENTITY", "INTEGER")
+	TypeSerial      = columnType("SERIAL", "INT AUTO_INCREMENT", "INT IDENTITY(1,1)", "NUMBER(10) GENERATED BY DEFAULT AS IDENTITY", "INTEGER")
+	TypeBigInt      = columnType("BIGINT", "BIGINT", "BIGINT", "NUMBER(19)", "INTEGER")
+	TypeInteger     = columnType("INTEGER", "INT", "INT", "NUMBER(10)", "INTEGER")
+	TypeSmallInt    = columnType("SMALLINT", "SMALLINT", "SMALLINT", "NUMBER(5)", "INTEGER")
+	TypeBoolean     = columnType("BOOLEAN", "BOOLEAN", "BIT", "NUMBER(1)", "BOOLEAN")
+	TypeDouble      = columnType("DOUBLE PRECISION", "DOUBLE", "FLOAT", "BINARY_DOUBLE", "REAL")
+	TypeText        = columnType("TEXT", "TEXT", "NVARCHAR(MAX)", "CLOB", "TEXT")
+	TypeBytes       = columnType("BYTEA", "LONGBLOB", "VARBINARY(MAX)", "BLOB", "BLOB")
+	TypeDate        = columnType("DATE", "DATE", "DATE", "DATE", "DATE")
+	TypeTimestamp   = columnType("TIMESTAMP", "DATETIME(6)", "DATETIME2", "TIMESTAMP", "TIMESTAMP")
+	TypeTimestampTZ = columnType("TIMESTAMPTZ", "DATETIME(6)", "DATETIMEOFFSET", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP")
+	TypeUUID        = columnType("UUID", "CHAR(36)", "UNIQUEIDENTIFIER", "RAW(16)", "TEXT")
+	TypeJSONB       = columnType("JSONB", "JSON", "NVARCHAR(MAX)", "CLOB", "TEXT")
+)
+
+func TypeVarchar(n int) ColumnType {
+	s := "(" + strconv.Itoa(n) + ")"
+	return columnType("VARCHAR"+s, "VARCHAR"+s, "NVARCHAR"+s, "VARCHAR2"+s, "VARCHAR"+s)
+}
+
+func TypeNumeric(precision, scale int) ColumnType {
+	s := "(" + strconv.Itoa(precision) + ", " + strconv.Itoa(scale) + ")"
+	return columnType("NUMERIC"+s, "DECIMAL"+s, "DECIMAL"+s, "NUMBER"+s, "NUMERIC"+s)
+}
+
+// TypeNamed is a column type with the same name in every dialect.
+func TypeNamed(name string) ColumnType {
+	return ColumnType{name: name}
+}
+
+// A ColumnOption is a constraint or default in a column definition.
+type ColumnOption string
+
+const (
+	ConstraintPrimaryKey ColumnOption = "PRIMARY KEY"
+	ConstraintNotNull    ColumnOption = "NOT NULL"
+	ConstraintUnique     ColumnOption = "UNIQUE"
+)
+
+//  DEFAULT expr
+func ConstraintDefault(expr string) ColumnOption {
+	return ColumnOption("DEFAULT " + expr)
+}
+
+//  REFERENCES table ( column )
+func ConstraintReferences(table, column string) ColumnOption {
+	return ColumnOption("REFERENCES " + table + " ( " + column + " )")
+}
+
+type columnDef struct {
+	name string
+	typ  ColumnType
+	opts []ColumnOption
+}
+
+func (c columnDef) writeTo(w *sqlWriter, d Dialect) {
+	w.WriteSQL(c.name, c.typ.nameIn(d))
+	for _, opt := range c.opts {
+		w.WriteSQL(string(opt))
+	}
+}
+
+type foreignKey struct {
+	columns    []string
+	table      string
+	refColumns []string
+	onDelete   string
+}
+
+// CreateTableStmt builds a CREATE TABLE statement.
+type CreateTableStmt struct {
+	table       string
+	ifNotExists bool
+	columns     []columnDef
+	primaryKey  []string
+	foreignKeys []foreignKey
+}
+
+func CreateTable(table string) CreateTableStmt {
+	return CreateTableStmt{table: table}
+}
+
+// Adds a column definition.
+//  name type [option0[ option1[ ...]]]
+func (s CreateTableStmt) Column(name string, typ ColumnType, opts ...ColumnOption) CreateTableStmt {
+	columns1 := make([]columnDef, 0, len(s.columns)+1)
+	columns1 = append(columns1, s.columns...)
+	s.columns = append(columns1, columnDef{name: name, typ: typ, opts: opts})
+	return s
+}
+
+// Adds a table primary key constraint.
+//  PRIMARY KEY ( column0[ , column1[ , ...]] )
+func (s CreateTableStmt) PrimaryKey(columns ...string) CreateTableStmt {
+	s.primaryKey = columns
+	return s
+}
+
+// Adds a table foreign key constraint.
+//  FOREIGN KEY ( column0[ , ...] ) REFERENCES table ( refColumn0[ , ...] )
+func (s CreateTableStmt) ForeignKey(columns []string, table string, refColumns ...string) CreateTableStmt {
+	fks1 := make([]foreignKey, 0, len(s.foreignKeys)+1)
+	fks1 = append(fks1, s.foreignKeys...)
+	s.foreignKeys = append(fks1, foreignKey{columns: columns, table: table, refColumns: refColumns})
+	return s
+}
+
+// Sets the ON DELETE action of the preceding ForeignKey.
+//  ... ON DELETE action
+func (s CreateTableStmt) OnDelete(action string) CreateTableStmt {
+	if len(s.foreignKeys) == 0 {
+		panic("qb: OnDelete must follow ForeignKey")
+	}
+
+	fks1 := make([]foreignKey, len(s.foreignKeys))
+	copy(fks1, s.foreignKeys)
+	fks1[len(fks1)-1].onDelete = action
+	s.foreignKeys = fks1
+	return s
+}
+
+// Makes the statement do nothing if the table exists. In the mssql dialect,
+// the statement is guarded with OBJECT_ID. This is not supported in the goracle
+// dialect.
+//  CREATE TABLE IF NOT EXISTS table ...
+func (s CreateTableStmt) IfNotExists() CreateTableStmt {
+	s.ifNotExists = true
+	return s
+}
+
+func (s CreateTableStmt) writeTo(w *sqlWriter, d Dialect) error {
+	switch {
+	case !s.ifNotExists:
+		w.WriteSQL("CREATE TABLE", s.table)
+	case d == DialectMssql:
+		w.WriteSQL("IF OBJECT_ID(N"+quoteString(s.table)+", N'U') IS NULL", "CREATE TABLE", s.table)
+	case d == DialectGoracle:
+		return fmt.Errorf("qb: CREATE TABLE IF NOT EXISTS is not supported in the goracle dialect")
+	default:
+		w.WriteSQL("CREATE TABLE IF NOT EXISTS", s.table)
+	}
+
+	w.WriteSQL("(")
+	for i, c := range s.columns {
+		if i > 0 {
+			w.WriteSQL(",")
+		}
+		c.writeTo(w, d)
+	}
+
+	if len(s.primaryKey) > 0 {
+		w.WriteSQL(",", "PRIMARY KEY", "(", strings.Join(s.primaryKey, " , "), ")")
+	}
+
+	for _, fk := range s.foreignKeys {
+		w.WriteSQL(",", "FOREIGN KEY", "(", strings.Join(fk.columns, " , "), ")")
+		w.WriteSQL("REFERENCES", fk.table, "(", strings.Join(fk.refColumns, " , "), ")")
+		if fk.onDelete != "" {
+			w.WriteSQL("ON DELETE", fk.onDelete)
+		}
+	}
+
+	w.WriteSQL(")")
+	return nil
+}
+
+func (s CreateTableStmt) Query() Query {
+	var q Query
+	q.w.WriteFragment(s)
+	return q
+}
+
+func (s CreateTableStmt) String() string {
+	return s.Query().String()
+}
+
+type alterAction struct {
+	add    *columnDef
+	drop   string
+	rename [2]string
+}
+
+// AlterTableStmt builds ALTER TABLE statements. Every action is rendered as a
+// statement of its own, since not all dialects can combine them.
+type AlterTableStmt struct {
+	table   string
+	actions []alterAction
+}
+
+func AlterTable(table string) AlterTableStmt {
+	return AlterTableStmt{table: table}
+}
+
+func (s AlterTableStmt) action(a alterAction) AlterTableStmt {
+	actions1 := make([]alterAction, 0, len(s.actions)+1)
+	actions1 = append(actions1, s.actions...)
+	s.actions = append(actions1, a)
+	return s
+}
+
+//  ALTER TABLE table ADD COLUMN name type [option0[ ...]]
+func (s AlterTableStmt) AddColumn(name string, typ ColumnType, opts ...ColumnOption) AlterTableStmt {
+	return s.action(alterAction{add: &columnDef{name: name, typ: typ, opts: opts}})
+}
+
+//  ALTER TABLE table DROP COLUMN name
+func (s AlterTableStmt) DropColumn(name string) AlterTableStmt {
+	return s.action(alterAction{drop: name})
+}
+
+//  ALTER TABLE table RENAME COLUMN from TO to
+//  EXEC sp_rename 'table.from', 'to', 'COLUMN' (mssql)
+func (s AlterTableStmt) RenameColumn(from, to string) AlterTableStmt {
+	return s.action(alterAction{rename: [2]string{from, to}})
+}
+
+func (s AlterTableStmt) writeTo(w *sqlWriter, d Dialect) error {
+	for i, a := range s.actions {
+		if i > 0 {
+			w.WriteSQL(";")
+		}
+
+		switch {
+		case a.add != nil:
+			w.WriteSQL("ALTER TABLE", s.table)
+			if d == DialectMssql || d == DialectGoracle {
+				w.WriteSQL("ADD")
+			} else {
+				w.WriteSQL("ADD COLUMN")
+			}
+			a.add.writeTo(w, d)
+		case a.drop != "":
+			w.WriteSQL("ALTER TABLE", s.table, "DROP COLUMN", a.drop)
+		case d == DialectMssql:
+			w.WriteSQL("EXEC sp_rename", quoteString(s.table+"."+a.rename[0]), ",", quoteString(a.rename[1]), ",", "'COLUMN'")
+		default:
+			w.WriteSQL("ALTER TABLE", s.table, "RENAME COLUMN", a.rename[0], "TO", a.rename[1])
+		}
+	}
+	return nil
+}
+
+func (s AlterTableStmt) Query() Query {
+	var q Query
+	q.w.WriteFragment(s)
+	return q
+}
+
+func (s AlterTableStmt) String() string {
+	return s.Query().String()
+}
+
+// CreateIndexStmt builds a CREATE INDEX statement.
+type CreateIndexStmt struct {
+	name         string
+	table        string
+	columns      []string
+	unique       bool
+	concurrently bool
+	ifNotExists  bool
+	where        []Predicate
+}
+
+func CreateIndex(name, table string, columns ...string) CreateIndexStmt {
+	return CreateIndexStmt{name: name, table: table, columns: columns}
+}
+
+//  CREATE UNIQUE INDEX ...
+func (s CreateIndexStmt) Unique() CreateIndexStmt {
+	s.unique = true
+	return s
+}
+
+// Builds the index without locking out writes. This is only rendered in the pq
+// and default dialects.
+//  CREATE INDEX CONCURRENTLY ...
+func (s CreateIndexStmt) Concurrently() CreateIndexStmt {
+	s.concurrently = true
+	return s
+}
+
+// This is not supported in the mssql and goracle dialects.
+//  CREATE INDEX IF NOT EXISTS ...
+func (s CreateIndexStmt) IfNotExists() CreateIndexStmt {
+	s.ifNotExists = true
+	return s
+}
+
+// Makes the index partial. This is not supported in the mysql and goracle
+// dialects. Most databases do not accept arguments in DDL statements, so the
+// predicate should be written with literals.
+//  CREATE INDEX ... WHERE predicate
+func (s CreateIndexStmt) Where(pred Predicate) CreateIndexStmt {
+	if pred.IsEmpty() {
+		return s
+	}
+
+	where1 := make([]Predicate, 0, len(s.where)+1)
+	where1 = append(where1, s.where...)
+	s.where = append(where1, pred)
+	return s
+}
+
+func (s CreateIndexStmt) writeTo(w *sqlWriter, d Dialect) error {
+	w.WriteSQL("CREATE")
+	if s.unique {
+		w.WriteSQL("UNIQUE")
+	}
+
+	w.WriteSQL("INDEX")
+	if s.concurrently && (d == DialectDefault || d == DialectPq) {
+		w.WriteSQL("CONCURRENTLY")
+	}
+
+	if s.ifNotExists {
+		if d == DialectMssql || d == DialectGoracle {
+			return fmt.Errorf("qb: CREATE INDEX IF NOT EXISTS is not supported in the %s dialect", dialectName(d))
+		}
+		w.WriteSQL("IF NOT EXISTS")
+	}
+
+	w.WriteSQL(s.name, "ON", s.table, "(", strings.Join(s.columns, " , "), ")")
+
+	if len(s.where) > 0 && (d == DialectMysql || d == DialectGoracle) {
+		return fmt.Errorf("qb: partial indexes are not supported in the %s dialect", dialectName(d))
+	}
+
+	for i, pred := range s.where {
+		if i == 0 {
+			w.WriteSQL("WHERE")
+		} else {
+			w.WriteSQL("AND")
+		}
+		w.Append(&pred.w)
+	}
+
+	return nil
+}
+
+func (s CreateIndexStmt) Query() Query {
+	var q Query
+	q.w.WriteFragment(s)
+	return q
+}
+
+func (s CreateIndexStmt) String() string {
+	return s.Query().String()
+}
+
+// DropTableStmt builds a DROP TABLE statement.
+type DropTableStmt struct {
+	tables   []string
+	ifExists bool
+	cascade  bool
+}
+
+// Drops the given tables. Dropping several tables in one statement is not
+// supported in the goracle dialect.
+func DropTable(tables ...string) DropTableStmt {
+	return DropTableStmt{tables: tables}
+}
+
+// This is not supported in the goracle dialect.
+//  DROP TABLE IF EXISTS ...
+func (s DropTableStmt) IfExists() DropTableStmt {
+	s.ifExists = true
+	return s
+}
+
+// Also drops the objects which depend on the tables. This is rendered as
+// CASCADE CONSTRAINTS in the goracle dialect, and is not supported in the
+// mssql and sqlite dialects.
+//  DROP TABLE ... CASCADE
+func (s DropTableStmt) Cascade() DropTableStmt {
+	s.cascade = true
+	return s
+}
+
+func (s DropTableStmt) writeTo(w *sqlWriter, d Dialect) error {
+	if d == DialectGoracle {
+		if len(s.tables) > 1 {
+			return fmt.Errorf("qb: DROP TABLE of several tables is not supported in the goracle dialect")
+		}
+		if s.ifExists {
+			return fmt.Errorf("qb: DROP TABLE IF EXISTS is not supported in the goracle dialect")
+		}
+	}
+
+	w.WriteSQL("DROP TABLE")
+	if s.ifExists {
+		w.WriteSQL("IF EXISTS")
+	}
+
+	w.WriteSQL(strings.Join(s.tables, " , "))
+
+	if s.cascade {
+		switch d {
+		case DialectMssql, DialectSqlite:
+			return fmt.Errorf("qb: DROP TABLE ... CASCADE is not supported in the %s dialect", dialectName(d))
+		case DialectGoracle:
+			w.WriteSQL("CASCADE CONSTRAINTS")
+		default:
+			w.WriteSQL("CASCADE")
+		}
+	}
+
+	return nil
+}
+
+func (s DropTableStmt) Query() Query {
+	var q Query
+	q.w.WriteFragment(s)
+	return q
+}
+
+func (s DropTableStmt) String() string {
+	return s.Query().String()
+}
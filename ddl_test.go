@@ -0,0 +1,144 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestDDL(t *testing.T) {
+	users := qb.CreateTable("users").
+		IfNotExists().
+		Column("id", qb.TypeBigSerial, qb.ConstraintPrimaryKey).
+		Column("email", qb.TypeVarchar(255), qb.ConstraintNotNull, qb.ConstraintUnique).
+		Column("org_id", qb.TypeBigInt, qb.ConstraintNotNull).
+		Column("created_at", qb.TypeTimestampTZ, qb.ConstraintNotNull, qb.ConstraintDefault("now()")).
+		ForeignKey([]string{"org_id"}, "orgs", "id").OnDelete("CASCADE")
+
+	tests := []struct {
+		name    string
+		expr    string
+		dialect qb.Dialect
+		query   qb.Query
+	}{
+		{
+			name:  "create table",
+			expr:  `CREATE TABLE IF NOT EXISTS users ( id BIGSERIAL PRIMARY KEY , email VARCHAR(255) NOT NULL UNIQUE , org_id BIGINT NOT NULL , created_at TIMESTAMPTZ NOT NULL DEFAULT now() , FOREIGN KEY ( org_id ) REFERENCES orgs ( id ) ON DELETE CASCADE )`,
+			query: users.Query(),
+		},
+		{
+			name:    "create table with mysql dialect",
+			expr:    `CREATE TABLE IF NOT EXISTS users ( id BIGINT AUTO_INCREMENT PRIMARY KEY , email VARCHAR(255) NOT NULL UNIQUE , org_id BIGINT NOT NULL , created_at DATETIME(6) NOT NULL DEFAULT now() , FOREIGN KEY ( org_id ) REFERENCES orgs ( id ) ON DELETE CASCADE )`,
+			dialect: qb.DialectMysql,
+			query:   users.Query(),
+		},
+		{
+			name:    "create table with mssql dialect",
+			expr:    `IF OBJECT_ID(N't', N'U') IS NULL CREATE TABLE t ( a INT IDENTITY(1,1) , b NVARCHAR(MAX) REFERENCES t2 ( id ) , PRIMARY KEY ( a , b ) )`,
+			dialect: qb.DialectMssql,
+			query: qb.CreateTable("t").
+				IfNotExists().
+				Column("a", qb.TypeSerial).
+				Column("b", qb.TypeText, qb.ConstraintReferences("t2", "id")).
+				PrimaryKey("a", "b").
+				Query(),
+		},
+		{
+			name:    "create table with a quote with mssql dialect",
+			expr:    `IF OBJECT_ID(N'[o''brien]', N'U') IS NULL CREATE TABLE [o'brien] ( a INT )`,
+			dialect: qb.DialectMssql,
+			query:   qb.CreateTable("[o'brien]").IfNotExists().Column("a", qb.TypeInteger).Query(),
+		},
+		{
+			name: "alter table",
+			expr: `ALTER TABLE t ADD COLUMN a UUID NOT NULL ; ALTER TABLE t DROP COLUMN b ; ALTER TABLE t RENAME COLUMN c TO d`,
+			query: qb.AlterTable("t").
+				AddColumn("a", qb.TypeUUID, qb.ConstraintNotNull).
+				DropColumn("b").
+				RenameColumn("c", "d").
+				Query(),
+		},
+		{
+			name:    "alter table with mssql dialect",
+			expr:    `ALTER TABLE t ADD a UNIQUEIDENTIFIER ; EXEC sp_rename 't.c' , 'd' , 'COLUMN'`,
+			dialect: qb.DialectMssql,
+			query: qb.AlterTable("t").
+				AddColumn("a", qb.TypeUUID).
+				RenameColumn("c", "d").
+				Query(),
+		},
+		{
+			name:    "rename with a quote with mssql dialect",
+			expr:    `EXEC sp_rename '[o''brien].c' , 'd''e' , 'COLUMN'`,
+			dialect: qb.DialectMssql,
+			query:   qb.AlterTable("[o'brien]").RenameColumn("c", "d'e").Query(),
+		},
+		{
+			name: "create index",
+			expr: `CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS users_email ON users ( org_id , email ) WHERE deleted_at IS NULL`,
+			query: qb.CreateIndex("users_email", "users", "org_id", "email").
+				Unique().
+				Concurrently().
+				IfNotExists().
+				Where(qb.And("deleted_at IS NULL")).
+				Query(),
+		},
+		{
+			name:    "create index with mssql dialect",
+			expr:    `CREATE INDEX t_a ON t ( a )`,
+			dialect: qb.DialectMssql,
+			query:   qb.CreateIndex("t_a", "t", "a").Concurrently().Query(),
+		},
+		{
+			name:  "drop table",
+			expr:  `DROP TABLE IF EXISTS t1 , t2 CASCADE`,
+			query: qb.DropTable("t1", "t2").IfExists().Cascade().Query(),
+		},
+		{
+			name:    "drop table with goracle dialect",
+			expr:    `DROP TABLE t1 CASCADE CONSTRAINTS`,
+			dialect: qb.DialectGoracle,
+			query:   qb.DropTable("t1").Cascade().Query(),
+		},
+		{
+			name:  "multiple",
+			expr:  `DROP TABLE t1 ; CREATE TABLE t1 ( a TEXT ) ;`,
+			query: qb.Multiple(qb.DropTable("t1").Query(), qb.CreateTable("t1").Column("a", qb.TypeText).Query()),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := test.query.DialectOption(test.dialect)
+			require.Equal(t, test.expr, q.SQL())
+			require.Empty(t, q.Args())
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		q := qb.CreateIndex("t_a", "t", "a").Where(qb.And("a > 0")).Query().DialectOption(qb.DialectMysql)
+		require.Panics(t, func() { q.SQL() })
+
+		q = qb.DropTable("t").Cascade().Query().DialectOption(qb.DialectMssql)
+		require.Panics(t, func() { q.SQL() })
+	})
+
+	t.Run("unsupported with goracle dialect", func(t *testing.T) {
+		tests := []struct {
+			query qb.Query
+			err   string
+		}{
+			{users.Query(), "qb: CREATE TABLE IF NOT EXISTS is not supported in the goracle dialect"},
+			{qb.CreateIndex("t_a", "t", "a").IfNotExists().Query(), "qb: CREATE INDEX IF NOT EXISTS is not supported in the goracle dialect"},
+			{qb.DropTable("t1", "t2").Query(), "qb: DROP TABLE of several tables is not supported in the goracle dialect"},
+			{qb.DropTable("t1").IfExists().Query(), "qb: DROP TABLE IF EXISTS is not supported in the goracle dialect"},
+		}
+
+		for _, tt := range tests {
+			q := tt.query.DialectOption(qb.DialectGoracle)
+			require.EqualError(t, recoverError(func() { q.SQL() }), tt.err)
+		}
+	})
+}
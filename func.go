@@ -0,0 +1,246 @@
+package qb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FuncExpr is a call of an SQL function, such as an aggregate. Like CaseExpr,
+// it is passed as an argument wherever expressions take arguments, such as to
+// SelectColumn, Pred, OrderByExpr and GroupByExpr:
+//  q := qb.Select("team_id").
+//  	SelectColumn("?", qb.Count("*").As("members")).
+//  	SelectColumn("?", qb.Count("*").Filter(qb.Col("active").Eq(true)).As("active")).
+//  	From("members").
+//  	GroupBy("team_id").
+//  	Having(qb.Pred("? > ?", qb.Count("*"), 10))
+//  // SELECT team_id , count(*) AS "members" , count(*) FILTER (WHERE active = $1 ) AS "active" FROM members
+//  // GROUP BY team_id HAVING count(*) > $2
+type FuncExpr struct {
+	name     string
+	distinct bool
+	args     []sqlWriter
+	filter   Predicate
+	alias    string
+}
+
+var funcNamePattern = regexp.MustCompile(`^` + namePattern + `$`)
+
+// Returns a call of the named function. Columns are passed as Col, and the
+// other arguments are written the same way as arguments to Pred: Lit values
+// are written as SQL, queries as subqueries, and other values are passed as
+// arguments:
+//  qb.Func("date_trunc", qb.Lit("'day'"), qb.Col("created_at"))
+//  // date_trunc('day', created_at)
+func Func(name string, args ...interface{}) FuncExpr {
+	if !funcNamePattern.MatchString(name) {
+		panic(fmt.Errorf("qb: invalid function name %q", name))
+	}
+
+	f := FuncExpr{name: name, args: make([]sqlWriter, len(args))}
+	for i, arg := range args {
+		f.args[i].writeOperand(arg)
+	}
+	return f
+}
+
+// writeOperand writes an operand of a function call or CASE expression: a Col
+// as a column name, and other values the same way as WriteValue.
+func (q *sqlWriter) writeOperand(v interface{}) {
+	if c, ok := v.(Col); ok {
+		q.writeChecked(checkColumn, string(c))
+	} else {
+		q.WriteValue(v)
+	}
+}
+
+// aggregate returns a call of an aggregate function of a column or an
+// expression.
+func aggregate(name string, expr interface{}) FuncExpr {
+	f := FuncExpr{name: name, args: make([]sqlWriter, 1)}
+	switch x := expr.(type) {
+	case string:
+		f.args[0].writeChecked(checkColumn, x)
+	case Col:
+		f.args[0].writeChecked(checkColumn, string(x))
+	default:
+		f.args[0].WriteValue(x)
+	}
+	return f
+}
+
+//  count(expr)
+// The aggregates take a column or an SQL expression, as a string or a Col, or
+// an expression such as a CaseExpr:
+//  qb.Count("*")
+//  qb.Sum(qb.Case().When(qb.Col("paid").Eq(true), qb.Col("amount")).Else(0))
+func Count(expr interface{}) FuncExpr {
+	return aggregate("count", expr)
+}
+
+//  count(DISTINCT expr)
+func CountDistinct(expr interface{}) FuncExpr {
+	f := aggregate("count", expr)
+	f.distinct = true
+	return f
+}
+
+//  sum(expr)
+func Sum(expr interface{}) FuncExpr {
+	return aggregate("sum", expr)
+}
+
+//  avg(expr)
+func Avg(expr interface{}) FuncExpr {
+	return aggregate("avg", expr)
+}
+
+//  coalesce(value0, value1, ...)
+// The values are written the same way as the arguments to Func.
+func Coalesce(values ...interface{}) FuncExpr {
+	return Func("coalesce", values...)
+}
+
+// Restricts the rows of an aggregate to those which match the predicate.
+// Predicates of several calls are parenthesized and combined with AND.
+//  count(*) FILTER (WHERE predicate)
+// In the mysql, mssql and goracle dialects, which have no FILTER clause, the
+// argument of the aggregate is written as a CASE expression instead:
+//  count(CASE WHEN predicate THEN 1 END)
+//  sum(CASE WHEN predicate THEN expr END)
+func (f FuncExpr) Filter(pred Predicate) FuncExpr {
+	if f.filter.IsEmpty() {
+		f.filter = pred
+	} else {
+		f.filter = andGuarded(f.filter, pred)
+	}
+	return f
+}
+
+// Aliases the result of the call. Double quotes in the alias are escaped.
+//  expr AS "alias"
+func (f FuncExpr) As(alias string) FuncExpr {
+	f.alias = alias
+	return f
+}
+
+func (f FuncExpr) String() string {
+	var w sqlWriter
+	w.WriteFragment(f)
+	return w.String()
+}
+
+// hasFilter reports whether the dialect has the FILTER clause of aggregates.
+func hasFilter(d Dialect) bool {
+	return d == DialectDefault || d == DialectPq || d == DialectSqlite
+}
+
+func (f FuncExpr) writeTo(w *sqlWriter, d Dialect) error {
+	args := f.args
+	filter := !f.filter.IsEmpty()
+	if filter && !hasFilter(d) {
+		if len(args) != 1 {
+			return fmt.Errorf("qb: FILTER of %s with %d arguments is not supported in the %s dialect", f.name, len(args), dialectName(d))
+		}
+
+		var arg sqlWriter
+		arg.WriteSQL("CASE", "WHEN")
+		arg.Append(&f.filter.w)
+		arg.WriteSQL("THEN")
+		if len(args[0].tokens) == 1 && args[0].tokens[0].sql == "*" {
+			arg.WriteSQL("1")
+		} else {
+			arg.Append(&args[0])
+		}
+		arg.WriteSQL("END")
+
+		args = []sqlWriter{arg}
+		filter = false
+	}
+
+	// The call is written as one token if none of its arguments is passed as
+	// an argument, e.g. count(*).
+	expanded := make([]sqlWriter, len(args))
+	inline := true
+	for i, arg := range args {
+		var err error
+		if expanded[i], err = arg.expand(d); err != nil {
+			return err
+		}
+		inline = inline && !expanded[i].hasArgs()
+	}
+
+	distinct := ""
+	if f.distinct {
+		distinct = "DISTINCT "
+	}
+
+	if inline {
+		sqls := make([]string, len(expanded))
+		for i, arg := range expanded {
+			sql := make([]string, len(arg.tokens))
+			for j, t := range arg.tokens {
+				sql[j] = t.sql
+			}
+			sqls[i] = strings.Join(sql, " ")
+		}
+		w.WriteSQL(f.name + "(" + distinct + strings.Join(sqls, ", ") + ")")
+	} else {
+		w.WriteSQL(f.name + "(" + strings.TrimSpace(distinct))
+		for i := range expanded {
+			if i > 0 {
+				w.WriteSQL(",")
+			}
+			w.Append(&expanded[i])
+		}
+		w.WriteSQL(")")
+	}
+
+	if filter {
+		w.WriteSQL("FILTER (WHERE")
+		w.Append(&f.filter.w)
+		w.WriteSQL(")")
+	}
+
+	if f.alias != "" {
+		w.WriteSQL("AS", `"`+strings.ReplaceAll(f.alias, `"`, `""`)+`"`)
+	}
+	return nil
+}
+
+func (f FuncExpr) mapArgs(m func(v interface{}) interface{}) fragment {
+	args := make([]sqlWriter, len(f.args))
+	for i := range f.args {
+		args[i] = f.args[i].mapArgs(m)
+	}
+	f.args = args
+	f.filter.w = f.filter.w.mapArgs(m)
+	return f
+}
+
+func (f FuncExpr) mapQueries(m func(q Query) Query) fragment {
+	args := make([]sqlWriter, len(f.args))
+	for i := range f.args {
+		args[i] = f.args[i].mapQueries(m)
+	}
+	f.args = args
+	f.filter.w = f.filter.w.mapQueries(m)
+	return f
+}
+
+// Appends a GROUP BY term, or, after a GROUP BY clause, another term, in
+// which each ? stands for the next argument, such as a function call:
+//  q.GroupByExpr("?", qb.Func("date_trunc", qb.Lit("'day'"), qb.Col("created_at")))
+//  // ... GROUP BY date_trunc('day', created_at)
+func (q Query) GroupByExpr(expr string, args ...interface{}) Query {
+	prefix := "GROUP BY"
+	if q.last == groupByExpr {
+		prefix = ","
+	}
+
+	q.last = groupByExpr
+	q.w.WriteSQL(prefix)
+	q.w.WriteExpr(expr, args...)
+	return q
+}
@@ -0,0 +1,141 @@
+package qb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestFunc(t *testing.T) {
+	tests := []struct {
+		name string
+		q    qb.Query
+		expr string
+		args []interface{}
+	}{
+		{
+			name: "aggregates",
+			q: qb.Select("team_id").
+				SelectColumn("?", qb.Count("*").As("n")).
+				SelectColumn("?", qb.CountDistinct(qb.Col("country"))).
+				SelectColumn("?", qb.Sum("amount")).
+				SelectColumn("?", qb.Coalesce(qb.Avg("score"), 0).As("score")).
+				From("members").
+				GroupBy("team_id").
+				Having(qb.Pred("? > ?", qb.Count("*"), 10)).
+				OrderByExpr("? DESC", qb.Sum("amount")),
+			expr: `SELECT team_id ,  count(*) AS "n" ,  count(DISTINCT country) ,  sum(amount) ,  coalesce( avg(score) , ? ) AS "score" FROM members GROUP BY team_id HAVING  count(*) > ? ORDER BY  sum(amount)  DESC`,
+			args: []interface{}{0, 10},
+		},
+		{
+			name: "func",
+			q: qb.WithDialectPQ().
+				SelectColumn("?", qb.Func("date_trunc", qb.Lit("'day'"), qb.Col("created_at")).As("day")).
+				SelectColumn("?", qb.Sum(qb.Case().When(qb.Col("paid").Eq(true), qb.Col("amount")).Else(0))).
+				From("orders").
+				GroupByExpr("?", qb.Func("date_trunc", qb.Lit("'day'"), qb.Col("created_at"))).
+				GroupByExpr("region"),
+			expr: `SELECT  date_trunc('day', created_at) AS "day" ,  sum( CASE WHEN paid = $1 THEN amount ELSE $2 END ) FROM orders GROUP BY  date_trunc('day', created_at) , region`,
+			args: []interface{}{true, 0},
+		},
+		{
+			name: "subquery",
+			q:    qb.SelectColumn("?", qb.Coalesce(qb.Select("max(id)").From("t"), qb.Lit("0"))),
+			expr: `SELECT  coalesce(( SELECT max(id) FROM t ), 0)`,
+			args: []interface{}{},
+		},
+		{
+			name: "quoted alias",
+			q:    qb.SelectColumn("?", qb.Count("*").As(`n" FROM t; DROP TABLE t; --`)).From("t"),
+			expr: `SELECT  count(*) AS "n"" FROM t; DROP TABLE t; --" FROM t`,
+			args: []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expr, tt.q.SQL())
+			require.Equal(t, tt.args, tt.q.Args())
+		})
+	}
+
+	require.Panics(t, func() { qb.Func("now(); DROP TABLE t; --") })
+}
+
+func TestFunc_Filter(t *testing.T) {
+	active := qb.Col("active").Eq(true)
+	query := func(d qb.Dialect) qb.Query {
+		return qb.DialectOption(d).
+			Select("team_id").
+			SelectColumn("?", qb.Count("*").Filter(active).As("active")).
+			SelectColumn("?", qb.Sum("amount").Filter(active).Filter(qb.Col("paid").Eq(true).Or("free = ?", false))).
+			SelectColumn("?", qb.CountDistinct("country").Filter(active)).
+			From("members").
+			GroupBy("team_id")
+	}
+
+	tests := []struct {
+		dialect qb.Dialect
+		expr    string
+	}{
+		{
+			dialect: qb.DialectPq,
+			expr:    `SELECT team_id ,  count(*) FILTER (WHERE active = $1 ) AS "active" ,  sum(amount) FILTER (WHERE ( active = $2 ) AND ( paid = $3 OR free = $4 ) ) ,  count(DISTINCT country) FILTER (WHERE active = $5 ) FROM members GROUP BY team_id`,
+		},
+		{
+			dialect: qb.DialectSqlite,
+			expr:    `SELECT team_id ,  count(*) FILTER (WHERE active = ? ) AS "active" ,  sum(amount) FILTER (WHERE ( active = ? ) AND ( paid = ? OR free = ? ) ) ,  count(DISTINCT country) FILTER (WHERE active = ? ) FROM members GROUP BY team_id`,
+		},
+		{
+			dialect: qb.DialectMysql,
+			expr:    `SELECT team_id ,  count( CASE WHEN active = ? THEN 1 END ) AS "active" ,  sum( CASE WHEN ( active = ? ) AND ( paid = ? OR free = ? ) THEN amount END ) ,  count(DISTINCT CASE WHEN active = ? THEN country END ) FROM members GROUP BY team_id`,
+		},
+		{
+			dialect: qb.DialectMssql,
+			expr:    `SELECT team_id ,  count( CASE WHEN active = @p1 THEN 1 END ) AS "active" ,  sum( CASE WHEN ( active = @p2 ) AND ( paid = @p3 OR free = @p4 ) THEN amount END ) ,  count(DISTINCT CASE WHEN active = @p5 THEN country END ) FROM members GROUP BY team_id`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.dialect), func(t *testing.T) {
+			q := query(tt.dialect)
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, []interface{}{true, true, true, false, true}, q.Args())
+		})
+	}
+
+	t.Run("raw or", func(t *testing.T) {
+		q := qb.WithDialectPQ().SelectColumn("?", qb.Count("*").Filter(qb.Pred("a OR b")).Filter(qb.Pred("c OR d")).Filter(active)).From("t")
+		require.Equal(t, `SELECT  count(*) FILTER (WHERE ( a OR b ) AND ( c OR d ) AND ( active = $1 ) ) FROM t`, q.SQL())
+	})
+
+	t.Run("several arguments", func(t *testing.T) {
+		q := qb.WithDialectMysql().SelectColumn("?", qb.Func("string_agg", qb.Col("name"), ",").Filter(active))
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: FILTER of string_agg with 2 arguments is not supported in the mysql dialect")
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		q := qb.Strict().SelectColumn("?", qb.Count("1; DROP TABLE t")).From("t")
+		require.EqualError(t, recoverError(func() { q.SQL() }), `qb: strict: "1; DROP TABLE t" is not a valid column; use qb.Raw to write raw SQL`)
+
+		q = qb.Strict().SelectColumn("?", qb.Coalesce(qb.Col("x); DROP TABLE t; --"), 0)).From("t")
+		require.EqualError(t, recoverError(func() { q.SQL() }), `qb: strict: "x); DROP TABLE t; --" is not a valid column; use qb.Raw to write raw SQL`)
+
+		q = qb.Strict().SelectColumn("?", qb.Func("date_trunc", qb.Lit("'day'"), qb.Col("created_at, (SELECT 1)"))).From("t")
+		require.Error(t, recoverError(func() { q.SQL() }))
+
+		q = qb.Strict().SelectColumn("?", qb.Coalesce(qb.Col("t.x"), 0)).From("t")
+		require.Equal(t, `SELECT  coalesce( t.x , ? ) FROM t`, q.SQL())
+
+		q = qb.Strict().SelectColumn("?", qb.Sum(qb.Raw("price * quantity"))).From("t")
+		require.Equal(t, `SELECT  sum(price * quantity) FROM t`, q.SQL())
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		q := qb.SelectColumn("?", qb.Count("*").Filter(qb.Pred("team_id IN ?", qb.Select("id").From("teams")))).From("members")
+		require.Equal(t, []qb.TableRef{{Table: "members"}, {Table: "teams"}}, q.Tables())
+	})
+}
@@ -0,0 +1,470 @@
+package qb
+
+import (
+	"strings"
+)
+
+// nestedQuery is a query written into another query: a subquery, a common
+// table expression, or a statement of Multiple. It is kept whole so that the
+// query can be inspected and rewritten.
+type nestedQuery struct {
+	q      Query
+	parens bool
+}
+
+func (n nestedQuery) writeTo(w *sqlWriter, d Dialect) error {
+	if n.parens {
+		w.WriteSQL("(")
+	}
+
+	w.Append(&n.q.w)
+
+	if n.parens {
+		w.WriteSQL(")")
+	}
+	return nil
+}
+
+func (n nestedQuery) mapArgs(f func(v interface{}) interface{}) fragment {
+	n.q.w = n.q.w.mapArgs(f)
+	return n
+}
+
+func (n nestedQuery) mapQueries(f func(q Query) Query) fragment {
+	n.q = f(n.q)
+	return n
+}
+
+// condition is the predicate of a WHERE, HAVING or ON clause.
+type condition struct {
+	p Predicate
+}
+
+func (c condition) writeTo(w *sqlWriter, d Dialect) error {
+	w.Append(&c.p.w)
+	return nil
+}
+
+func (c condition) mapArgs(f func(v interface{}) interface{}) fragment {
+	c.p.w = c.p.w.mapArgs(f)
+	return c
+}
+
+func (c condition) mapQueries(f func(q Query) Query) fragment {
+	c.p.w = c.p.w.mapQueries(f)
+	return c
+}
+
+// A TableRef is a reference to a table in a query.
+type TableRef struct {
+	// Table is the name of the table, as written in the query.
+	Table string
+	// Alias is the alias of the table, if any.
+	Alias string
+}
+
+// Name returns the name by which the columns of the table are qualified: its
+// alias if it has one, and otherwise its name.
+func (r TableRef) Name() string {
+	if r.Alias != "" {
+		return r.Alias
+	}
+	return r.Table
+}
+
+func (ref tableRef) public() TableRef {
+	var r TableRef
+	if len(ref.names) > 0 {
+		r.Table = ref.names[0]
+	}
+
+	if len(ref.names) > 1 {
+		r.Alias = ref.names[len(ref.names)-1]
+	}
+	return r
+}
+
+// nested returns the queries nested directly in the tokens of a writer.
+func nested(w *sqlWriter) []Query {
+	var qs []Query
+	for _, t := range w.tokens {
+		switch f := t.frag.(type) {
+		case nestedQuery:
+			qs = append(qs, f.q)
+		case condition:
+			qs = append(qs, nested(&f.p.w)...)
+		case CaseExpr:
+			qs = append(qs, nested(&f.w)...)
+			qs = append(qs, nested(&f.els)...)
+		case FuncExpr:
+			for i := range f.args {
+				qs = append(qs, nested(&f.args[i])...)
+			}
+			qs = append(qs, nested(&f.filter.w)...)
+		}
+	}
+	return qs
+}
+
+// Calls f for the query and then for every query nested in it, such as
+// subqueries and common table expressions, in the order in which they are
+// written.
+func (q Query) Walk(f func(q Query)) {
+	f(q)
+	for _, sq := range nested(&q.w) {
+		sq.Walk(f)
+	}
+}
+
+// Rewrites the query and every query nested in it with f. Nested queries are
+// rewritten before the queries which contain them.
+func (q Query) Rewrite(f func(q Query) Query) Query {
+	q.w = q.w.mapQueries(func(sq Query) Query {
+		return sq.Rewrite(f)
+	})
+	return f(q)
+}
+
+// Returns the tables referenced by the query and the queries nested in it,
+// in the order in which they are written. Tables in raw SQL, such as that
+// passed to Append, are not included.
+func (q Query) Tables() []TableRef {
+	var refs []TableRef
+	q.Walk(func(q Query) {
+		for _, ref := range q.tables {
+			refs = append(refs, ref.public())
+		}
+	})
+	return refs
+}
+
+// Returns the predicates of the WHERE, HAVING and ON clauses of the query and
+// the queries nested in it.
+func (q Query) Predicates() []Predicate {
+	var preds []Predicate
+	q.Walk(func(q Query) {
+		for _, t := range q.w.tokens {
+			if c, ok := t.frag.(condition); ok {
+				preds = append(preds, c.p)
+			}
+		}
+	})
+	return preds
+}
+
+// Returns the columns referenced by the query and the queries nested in it,
+// as written, e.g. "u.id". The columns are found by scanning the SQL of the
+// query for identifiers which are not keywords, functions, tables or aliases.
+func (q Query) Columns() []string {
+	var columns []string
+	seen := map[string]bool{}
+	q.Walk(func(q Query) {
+		for _, column := range scanColumns(q.columnSQL()) {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	})
+	return columns
+}
+
+// columnSQL returns the SQL of the query in which tables and nested queries
+// are replaced by placeholders.
+func (q Query) columnSQL() string {
+	isTable := map[int]bool{}
+	for _, ref := range q.tables {
+		isTable[ref.at] = true
+	}
+
+	var sql []string
+	var write func(w *sqlWriter, top bool)
+	write = func(w *sqlWriter, top bool) {
+		for i, t := range w.tokens {
+			switch f := t.frag.(type) {
+			case nil:
+				if top && isTable[i] {
+					sql = append(sql, "?")
+				} else {
+					sql = append(sql, t.sql)
+				}
+			case condition:
+				write(&f.p.w, false)
+			case nestedQuery:
+				sql = append(sql, "(?)")
+			case CaseExpr:
+				write(&f.w, false)
+				write(&f.els, false)
+				sql = append(sql, "END")
+			case FuncExpr:
+				sql = append(sql, f.name+"(")
+				for i := range f.args {
+					write(&f.args[i], false)
+				}
+				sql = append(sql, ")")
+				write(&f.filter.w, false)
+			case JSONExpr:
+				sql = append(sql, f.p.column)
+			case RankExpr:
+				sql = append(sql, f.m.columns...)
+			case nullsOrder:
+				sql = append(sql, f.sql)
+			default:
+				sql = append(sql, "?")
+			}
+		}
+	}
+
+	write(&q.w, true)
+	return strings.Join(sql, " ")
+}
+
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		ALL AND ANY AS ASC BETWEEN BY CASE CONFLICT CROSS DEFAULT DELETE
+		DESC DISTINCT DO DUPLICATE ELSE END ESCAPE EXCEPT EXISTS FALSE FETCH
+		FILTER FIRST FOR FROM FULL GROUP HAVING ILIKE IN INNER INSERT
+		INTERSECT INTERVAL INTO IS JOIN KEY LAST LATERAL LEFT LIKE LIMIT
+		LOCKED NATURAL NEXT NO NOT NOTHING NOWAIT NULL NULLS OF OFFSET ON
+		ONLY OR ORDER OUTER OVER PARTITION RECURSIVE RETURNING RIGHT ROW
+		ROWS SELECT SET SHARE SKIP SOME THEN TRUE UNION UPDATE USING VALUES
+		WHEN WHERE WINDOW WITH`) {
+		sqlKeywords[kw] = true
+	}
+}
+
+// scanColumns returns the identifiers in SQL which are not keywords,
+// functions or aliases.
+func scanColumns(sql string) []string {
+	toks, err := lexSQL(sql, DialectDefault)
+	if err != nil {
+		return nil
+	}
+
+	var columns []string
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.kind != wordToken && t.kind != quotedToken {
+			continue
+		}
+
+		start := i
+		for i+2 < len(toks) && toks[i+1].is(".") &&
+			(toks[i+2].kind == wordToken || toks[i+2].kind == quotedToken) {
+			i += 2
+		}
+
+		switch {
+		case t.kind == wordToken && sqlKeywords[strings.ToUpper(t.text)] && i == start:
+			// A keyword.
+		case start > 0 && (toks[start-1].is("AS") || toks[start-1].is("::")):
+			// An alias or a type.
+		case i+1 < len(toks) && toks[i+1].is("("):
+			// A function.
+		case i+2 < len(toks) && toks[i+1].is("AS") && toks[i+2].is("("):
+			// The name of a common table expression.
+		default:
+			columns = append(columns, tokensText(toks[start:i+1]))
+		}
+	}
+	return columns
+}
+
+// Adds a predicate to every reference to the given table in the query and
+// the queries nested in it, such as subqueries and common table expressions.
+// The predicate is returned by f for each reference, so that it can qualify
+// its columns with the name of the reference:
+//  q = q.FilterTable("orders", func(ref qb.TableRef) qb.Predicate {
+//  	return qb.Pred(ref.Name()+".tenant_id = ?", tenantID)
+//  })
+//
+// The predicate is added to the ON condition of a table joined with one, and
+// otherwise to the WHERE clause of the statement, which is created if needed;
+// the existing condition is parenthesized. The rows of a table joined with
+// RIGHT or FULL JOIN are kept whatever its ON condition, so its predicate is
+// added to the WHERE clause, which also drops the rows in which it is NULL. A table is matched by its name,
+// with or without a schema, also in a list of tables such as "users, orders". Tables written in
+// raw SQL and the targets of INSERT statements are not filtered.
+func (q Query) FilterTable(table string, f func(ref TableRef) Predicate) Query {
+	return q.Rewrite(func(q Query) Query {
+		return q.filterTable(table, f)
+	})
+}
+
+// filterTable adds a predicate to every reference to the given table in the
+// query, but not in the queries nested in it.
+func (q Query) filterTable(table string, f func(ref TableRef) Predicate) Query {
+	for i := 0; i < len(q.tables); i++ {
+		ref := q.tables[i]
+		if ref.t == insertIntoExpr || !matchesTable(ref.public().Table, table) {
+			continue
+		}
+
+		if pred := f(ref.public()); !pred.IsEmpty() {
+			q = q.addCondition(ref.at, pred)
+		}
+	}
+	return q
+}
+
+func matchesTable(name, table string) bool {
+	unquote := func(s string) string {
+		return strings.Trim(s, "\"`[]")
+	}
+
+	name, table = unquote(name), unquote(table)
+	if strings.EqualFold(name, table) {
+		return true
+	}
+
+	if i := strings.LastIndex(name, "."); i >= 0 && !strings.Contains(table, ".") {
+		return strings.EqualFold(unquote(name[i+1:]), table)
+	}
+	return false
+}
+
+// clauseStart reports whether a token starts a clause which follows the
+// WHERE clause of a statement, or is the WHERE clause.
+func clauseStart(t token) bool {
+	switch t.frag.(type) {
+	case pagination, locking:
+		return true
+	}
+
+	sql := strings.ToUpper(t.sql)
+	for _, kw := range []string{
+		"WHERE", "GROUP BY", "HAVING", "WINDOW", "ORDER BY", "LIMIT", "OFFSET",
+		"FETCH", "UNION", "INTERSECT", "EXCEPT", "RETURNING", "ON CONFLICT",
+		"ON DUPLICATE", ";",
+	} {
+		if sql == kw || strings.HasPrefix(sql, kw+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func isWhere(t token) bool {
+	sql := strings.ToUpper(t.sql)
+	return sql == "WHERE" || strings.HasPrefix(sql, "WHERE ")
+}
+
+// clauseAfter returns the index of the first token after the i-th which
+// starts a clause, or the length of the query.
+func (q Query) clauseAfter(i int) int {
+	for i++; i < q.w.Len(); i++ {
+		if clauseStart(q.w.tokens[i]) {
+			break
+		}
+	}
+	return i
+}
+
+// insert inserts tokens before the i-th token, keeping the positions of the
+// tables of the query.
+func (q Query) insert(i int, ts ...token) Query {
+	q.w.insert(i, ts...)
+
+	tables1 := make([]tableRef, len(q.tables))
+	for j, ref := range q.tables {
+		if ref.at >= i {
+			ref.at += len(ts)
+		}
+		tables1[j] = ref
+	}
+	q.tables = tables1
+	return q
+}
+
+// addCondition adds a predicate for the table written at the given position:
+// to its ON condition if it has one which filters its rows, and otherwise to
+// the WHERE clause. The existing condition is parenthesized, so that the
+// predicate applies to the whole of it even if it has an OR which is written
+// as SQL.
+func (q Query) addCondition(at int, pred Predicate) Query {
+	if at+2 < q.w.Len() && q.w.tokens[at+1].sql == "ON" && !q.preserved(at) {
+		if c, ok := q.w.fragmentAt(at + 2).(condition); ok {
+			q.w.replace(at+2, condition{andGuarded(c.p, pred)})
+			return q
+		}
+	}
+
+	i := q.clauseAfter(at)
+	if i == q.w.Len() || !isWhere(q.w.tokens[i]) {
+		if i == q.w.Len() {
+			q.last = whereExpr
+		}
+		return q.insert(i, token{sql: "WHERE"}, token{frag: condition{pred}})
+	}
+
+	end := q.clauseAfter(i)
+	if c, ok := q.w.fragmentAt(i + 1).(condition); ok && end == i+2 && q.w.tokens[i].sql == "WHERE" {
+		q.w.replace(i+1, condition{andGuarded(c.p, pred)})
+		return q
+	}
+
+	if end == q.w.Len() {
+		q.last = whereExpr
+	}
+	q = q.insert(end, token{sql: ")"}, token{sql: "AND"}, token{frag: condition{AndP(pred)}})
+
+	// The WHERE keyword may be written with the condition as one token, e.g.
+	// by Append.
+	if t := q.w.tokens[i]; len(t.sql) > len("WHERE") {
+		rest := t
+		rest.sql = strings.TrimSpace(t.sql[len("WHERE"):])
+		q.w.replaceSQL(i, "WHERE")
+		q = q.insert(i+1, rest)
+	}
+	return q.insert(i+1, token{sql: "("})
+}
+
+// preserved reports whether the table written at the given position is joined
+// with RIGHT or FULL JOIN, so that its rows are kept whatever its ON condition.
+func (q Query) preserved(at int) bool {
+	if at == 0 {
+		return false
+	}
+
+	words := strings.Fields(strings.ToUpper(q.w.tokens[at-1].sql))
+	return len(words) > 1 && words[len(words)-1] == "JOIN" &&
+		(words[0] == "RIGHT" || words[0] == "FULL")
+}
+
+// andGuarded returns the conjunction of two predicates, of which each is
+// parenthesized unless it already consists of parenthesized terms.
+func andGuarded(p, pred Predicate) Predicate {
+	if !guarded(p) {
+		p = AndP(p)
+	}
+	return p.AndP(pred)
+}
+
+// guarded reports whether a predicate consists of parenthesized terms joined
+// with AND, such as those built by AndP, so that another term can be added to
+// it without parenthesizing it again.
+func guarded(p Predicate) bool {
+	depth := 0
+	for _, t := range p.w.tokens {
+		switch {
+		case t.frag != nil || t.isArg:
+			if depth == 0 {
+				return false
+			}
+		case t.sql == "(":
+			depth++
+		case t.sql == ")":
+			depth--
+			if depth < 0 {
+				return false
+			}
+		case depth == 0 && t.sql != "AND":
+			return false
+		case strings.Count(t.sql, "(") != strings.Count(t.sql, ")"):
+			return false
+		}
+	}
+	return depth == 0 && p.w.Len() > 0
+}
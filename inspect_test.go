@@ -0,0 +1,208 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestQuery_Tables(t *testing.T) {
+	q := qb.With("recent", qb.Select("*").From("orders").Where(qb.Pred("created_at > now()"))).
+		Select("r.id", "u.name").
+		FromAs("recent", "r").
+		JoinAsOn("users", "u", qb.Pred("u.id = r.user_id")).
+		Where(qb.Pred("u.id IN ?", qb.Select("user_id").From("admins")))
+
+	require.Equal(t, []qb.TableRef{
+		{Table: "recent", Alias: "r"},
+		{Table: "users", Alias: "u"},
+		{Table: "orders"},
+		{Table: "admins"},
+	}, q.Tables())
+
+	require.Equal(t, []string{"r.id", "u.name", "u.id", "r.user_id", "created_at", "user_id"}, q.Columns())
+
+	var preds []string
+	for _, p := range q.Predicates() {
+		preds = append(preds, p.String())
+	}
+	require.Equal(t, []string{"u.id = r.user_id", "u.id IN ( SELECT user_id FROM admins )", "created_at > now()"}, preds)
+
+	require.Equal(t, "orders", qb.TableRef{Table: "orders"}.Name())
+	require.Equal(t, "o", qb.TableRef{Table: "orders", Alias: "o"}.Name())
+}
+
+func TestQuery_FilterTable(t *testing.T) {
+	tenant := func(ref qb.TableRef) qb.Predicate {
+		return qb.Pred(ref.Name()+".tenant_id = ?", 7)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		args  []interface{}
+		query func() qb.Query
+	}{
+		{
+			name: "from without where",
+			expr: `SELECT * FROM orders WHERE orders.tenant_id = ? ORDER BY id LIMIT 10`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.Select("*").From("orders").OrderBy("id").Limit(10)
+			},
+		},
+		{
+			name: "where with or",
+			expr: `SELECT * FROM orders AS "o" WHERE ( a = ? OR b = ? AND c = ? ) AND ( o.tenant_id = ? ) GROUP BY a`,
+			args: []interface{}{1, 2, 3, 7},
+			query: func() qb.Query {
+				return qb.Select("*").FromAs("orders", "o").
+					Where(qb.Pred("a = ?", 1).Or("b = ?", 2)).
+					Where(qb.Pred("c = ?", 3)).
+					GroupBy("a")
+			},
+		},
+		{
+			name: "raw or",
+			expr: `SELECT * FROM orders WHERE ( a = ? OR b = ? ) AND ( orders.tenant_id = ? )`,
+			args: []interface{}{1, 2, 7},
+			query: func() qb.Query {
+				return qb.Select("*").From("orders").Where(qb.Pred("a = ? OR b = ?", 1, 2))
+			},
+		},
+		{
+			name: "appended where",
+			expr: `SELECT * FROM orders WHERE ( a = 1 OR b = 2 ) AND ( orders.tenant_id = ? ) ORDER BY id`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.Select("*").From("orders").Append("WHERE a = 1 OR b = 2").OrderBy("id")
+			},
+		},
+		{
+			name: "raw or in subquery",
+			expr: `SELECT * FROM users WHERE id IN ( SELECT user_id FROM orders WHERE ( a = 1 OR b = 2 ) AND ( orders.tenant_id = ? ) )`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.Select("*").From("users").
+					Where(qb.Pred("id IN ?", qb.Select("user_id").From("orders").Where(qb.Pred("a = 1 OR b = 2"))))
+			},
+		},
+		{
+			name: "comma join",
+			expr: `SELECT * FROM users u , orders o WHERE ( o.user_id = u.id OR u.admin ) AND ( o.tenant_id = ? )`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.Select("*").From("users u, orders o").Where(qb.Pred("o.user_id = u.id OR u.admin"))
+			},
+		},
+		{
+			name: "join on",
+			expr: `SELECT * FROM users u LEFT JOIN orders o ON ( o.user_id = u.id ) AND ( o.tenant_id = ? ) WHERE u.id = ?`,
+			args: []interface{}{7, 1},
+			query: func() qb.Query {
+				return qb.Select("*").From("users u").
+					LeftJoinOn("orders o", qb.Pred("o.user_id = u.id")).
+					Where(qb.Pred("u.id = ?", 1))
+			},
+		},
+		{
+			name: "right join",
+			expr: `SELECT * FROM customers c RIGHT JOIN orders o ON o.cid = c.id WHERE o.tenant_id = ?`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.Select("*").From("customers c").RightJoinOn("orders o", qb.Pred("o.cid = c.id"))
+			},
+		},
+		{
+			name: "full join",
+			expr: `SELECT * FROM customers c FULL JOIN orders o ON o.cid = c.id WHERE ( c.active ) AND ( o.tenant_id = ? )`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.Select("*").From("customers c").FullJoinOn("orders o", qb.Pred("o.cid = c.id")).Where(qb.Pred("c.active"))
+			},
+		},
+		{
+			name: "parsed right outer join",
+			expr: `SELECT * FROM customers c RIGHT OUTER JOIN orders o ON o.cid = c.id WHERE o.tenant_id = ?`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				q, err := qb.Parse(`SELECT * FROM customers c RIGHT OUTER JOIN orders o ON o.cid = c.id`, qb.DialectDefault)
+				require.NoError(t, err)
+				return q
+			},
+		},
+		{
+			name: "subquery and cte",
+			expr: `WITH big AS ( SELECT * FROM public.orders WHERE ( total > ? ) AND ( public.orders.tenant_id = ? ) ) SELECT * FROM big WHERE id IN ( SELECT order_id FROM orders WHERE orders.tenant_id = ? )`,
+			args: []interface{}{100, 7, 7},
+			query: func() qb.Query {
+				return qb.With("big", qb.Select("*").From("public.orders").Where(qb.Pred("total > ?", 100))).
+					Select("*").From("big").
+					Where(qb.Pred("id IN ?", qb.Select("order_id").From("orders")))
+			},
+		},
+		{
+			name: "union",
+			expr: `SELECT id FROM orders WHERE orders.tenant_id = ? UNION SELECT id FROM orders WHERE orders.tenant_id = ?`,
+			args: []interface{}{7, 7},
+			query: func() qb.Query {
+				return qb.Select("id").From("orders").Union().Select("id").From("orders")
+			},
+		},
+		{
+			name: "update and delete",
+			expr: `UPDATE orders SET a = ? WHERE orders.tenant_id = ? RETURNING id ; DELETE FROM orders WHERE ( id = ? ) AND ( orders.tenant_id = ? ) ;`,
+			args: []interface{}{1, 7, 2, 7},
+			query: func() qb.Query {
+				return qb.Multiple(
+					qb.Update("orders").Set("a = ?", 1).Returning("id"),
+					qb.DeleteFrom("orders").Where(qb.Pred("id = ?", 2)))
+			},
+		},
+		{
+			name: "insert select",
+			expr: `INSERT INTO orders ( id ) SELECT id FROM orders WHERE orders.tenant_id = ?`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.InsertInto("orders", "id").Select("id").From("orders")
+			},
+		},
+		{
+			name: "parsed",
+			expr: `SELECT * FROM orders o JOIN items i ON i.order_id = o.id WHERE ( o.a = $1 OR o.b = $2 ) AND ( o.tenant_id = $3 ) AND o.c = $4`,
+			args: []interface{}{qb.Placeholder(1), qb.Placeholder(2), 7, 3},
+			query: func() qb.Query {
+				q, err := qb.Parse(`SELECT * FROM orders o JOIN items i ON i.order_id = o.id WHERE o.a = $1 OR o.b = $2`, qb.DialectPq)
+				require.NoError(t, err)
+				return q
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query().FilterTable("orders", tenant)
+			if tt.name == "parsed" {
+				q = q.Where(qb.Pred("o.c = ?", 3))
+			}
+
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+}
+
+func TestQuery_Rewrite(t *testing.T) {
+	q := qb.Select("*").From("a").Where(qb.Pred("id IN ?", qb.Select("id").From("b")))
+
+	var order []string
+	q = q.Rewrite(func(q qb.Query) qb.Query {
+		order = append(order, q.Tables()[0].Table)
+		return q.Limit(1)
+	})
+
+	require.Equal(t, []string{"b", "a"}, order)
+	require.Equal(t, `SELECT * FROM a WHERE id IN ( SELECT id FROM b LIMIT 1 ) LIMIT 1`, q.SQL())
+}
@@ -0,0 +1,220 @@
+package qb
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a path into the JSON document in a column. Its values are
+// expressions which are rendered in the dialect of the query, and are passed
+// as arguments wherever expressions take arguments, such as to SelectColumn,
+// Pred and OrderByExpr:
+//  p := qb.JSON("data").Path("address", "city")
+//  q := qb.Select("id").SelectColumn("? AS city", p.Text()).From("users").Where(qb.Pred("? = ?", p.Text(), "Oslo"))
+//  // pq:    SELECT id , data->'address'->>'city' AS city FROM users WHERE data->'address'->>'city' = $1
+//  // mysql: SELECT id , JSON_UNQUOTE(JSON_EXTRACT(data, '$.address.city')) AS city FROM users ...
+//  // mssql: SELECT id , JSON_VALUE(data, '$.address.city') AS city FROM users ...
+// Keys which are integers index arrays.
+type JSONPath struct {
+	column string
+	keys   []string
+}
+
+func JSON(column string) JSONPath {
+	return JSONPath{column: column}
+}
+
+// Extends the path with the given keys.
+func (p JSONPath) Path(keys ...string) JSONPath {
+	keys1 := make([]string, 0, len(p.keys)+len(keys))
+	keys1 = append(keys1, p.keys...)
+	p.keys = append(keys1, keys...)
+	return p
+}
+
+// The JSON value at the path.
+//  column->'key'
+func (p JSONPath) Expr() JSONExpr {
+	return JSONExpr{p: p}
+}
+
+// The value at the path as text, unquoted if it is a string.
+//  column->>'key'
+func (p JSONPath) Text() JSONExpr {
+	return JSONExpr{p: p, text: true}
+}
+
+// Returns the SQL of the JSON value at the path in the pq dialect.
+func (p JSONPath) String() string {
+	return p.sql(DialectPq, false)
+}
+
+//  column @> ?
+// The value is marshalled to JSON; if it cannot be, the query fails to
+// render. Containment is supported in the pq and mysql dialects.
+func (p JSONPath) Contains(v interface{}) Predicate {
+	c := jsonContains{p: p}
+	if b, err := json.Marshal(v); err != nil {
+		c.err = fmt.Errorf("qb: cannot marshal JSON value: %w", err)
+	} else {
+		c.value = string(b)
+	}
+
+	var pred Predicate
+	pred.count = 1
+	pred.w.WriteFragment(c)
+	return pred
+}
+
+// Whether the object at the path has the given key.
+//  column ? 'key'
+func (p JSONPath) HasKey(key string) Predicate {
+	var pred Predicate
+	pred.count = 1
+	pred.w.WriteFragment(jsonHasKey{p, key})
+	return pred
+}
+
+// jsonPath returns the path in the SQL/JSON path language of mysql, sqlite,
+// mssql and goracle.
+func (p JSONPath) jsonPath() string {
+	path := "$"
+	for _, key := range p.keys {
+		switch {
+		case isIndex(key):
+			path += "[" + key + "]"
+		case jsonKeyPattern.MatchString(key):
+			path += "." + key
+		default:
+			path += `."` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key) + `"`
+		}
+	}
+	return path
+}
+
+var jsonKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func isIndex(key string) bool {
+	_, err := strconv.ParseUint(key, 10, 31)
+	return err == nil
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sql returns the SQL of the value at the path, or of its text.
+func (p JSONPath) sql(d Dialect, text bool) string {
+	switch d {
+	case DialectMysql:
+		sql := "JSON_EXTRACT(" + p.column + ", " + quoteString(p.jsonPath()) + ")"
+		if text {
+			sql = "JSON_UNQUOTE(" + sql + ")"
+		}
+		return sql
+	case DialectSqlite:
+		return "json_extract(" + p.column + ", " + quoteString(p.jsonPath()) + ")"
+	case DialectMssql, DialectGoracle:
+		if text {
+			return "JSON_VALUE(" + p.column + ", " + quoteString(p.jsonPath()) + ")"
+		}
+		return "JSON_QUERY(" + p.column + ", " + quoteString(p.jsonPath()) + ")"
+	default:
+		if len(p.keys) == 0 {
+			if text {
+				return p.column + " #>> '{}'"
+			}
+			return p.column
+		}
+
+		sql := p.column
+		for i, key := range p.keys {
+			op := "->"
+			if text && i == len(p.keys)-1 {
+				op = "->>"
+			}
+			if isIndex(key) {
+				sql += op + key
+			} else {
+				sql += op + quoteString(key)
+			}
+		}
+		return sql
+	}
+}
+
+// JSONExpr is the JSON value at a path, or its text.
+type JSONExpr struct {
+	p    JSONPath
+	text bool
+}
+
+func (e JSONExpr) writeTo(w *sqlWriter, d Dialect) error {
+	w.WriteSQL(e.p.sql(d, e.text))
+	return nil
+}
+
+type jsonContains struct {
+	p     JSONPath
+	value string
+	err   error
+}
+
+func (c jsonContains) writeTo(w *sqlWriter, d Dialect) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	switch d {
+	case DialectMysql:
+		w.WriteSQL("JSON_CONTAINS(" + c.p.column + ",")
+		w.WriteArg(c.value)
+		w.WriteSQL(",")
+		w.WriteArg(c.p.jsonPath())
+		w.WriteSQL(")")
+	case DialectDefault, DialectPq:
+		w.WriteSQL(c.p.sql(d, false), "@>")
+		w.WriteArg(c.value)
+		w.WriteSQL("::jsonb")
+	default:
+		return fmt.Errorf("qb: JSON containment is not supported in the %s dialect", dialectName(d))
+	}
+	return nil
+}
+
+type jsonHasKey struct {
+	p   JSONPath
+	key string
+}
+
+func (h jsonHasKey) writeTo(w *sqlWriter, d Dialect) error {
+	path := h.p.Path(h.key).jsonPath()
+	switch d {
+	case DialectMysql:
+		w.WriteSQL("JSON_CONTAINS_PATH(" + h.p.column + ", 'one',")
+		w.WriteArg(path)
+		w.WriteSQL(")")
+	case DialectSqlite:
+		w.WriteSQL("json_type(" + h.p.column + ",")
+		w.WriteArg(path)
+		w.WriteSQL(") IS NOT NULL")
+	case DialectMssql:
+		w.WriteSQL("JSON_PATH_EXISTS(" + h.p.column + ",")
+		w.WriteArg(path)
+		w.WriteSQL(") = 1")
+	case DialectGoracle:
+		// The path of JSON_EXISTS must be a literal.
+		w.WriteSQL("JSON_EXISTS(" + h.p.column + ", " + quoteString(path) + ")")
+	case DialectPq:
+		w.WriteSQL(h.p.sql(d, false), "?")
+		w.WriteArg(h.key)
+	default:
+		// The ? operator cannot be told apart from the placeholders of the
+		// default dialect.
+		return fmt.Errorf("qb: JSON key existence is not supported in the %s dialect", dialectName(d))
+	}
+	return nil
+}
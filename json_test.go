@@ -0,0 +1,129 @@
+package qb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestJSON(t *testing.T) {
+	city := qb.JSON("data").Path("address", "city")
+	query := func(d qb.Dialect) qb.Query {
+		return qb.DialectOption(d).Select("id").SelectColumn("? AS city", city.Text()).From("users").
+			Where(qb.Pred("? = ?", city.Text(), "Oslo")).
+			OrderByExpr("? DESC", qb.JSON("data").Path("tags", "0").Text())
+	}
+
+	tests := []struct {
+		dialect qb.Dialect
+		expr    string
+	}{
+		{qb.DialectPq, `SELECT id ,  data->'address'->>'city'  AS city FROM users WHERE  data->'address'->>'city' = $1 ORDER BY  data->'tags'->>0  DESC`},
+		{qb.DialectMysql, `SELECT id ,  JSON_UNQUOTE(JSON_EXTRACT(data, '$.address.city'))  AS city FROM users WHERE  JSON_UNQUOTE(JSON_EXTRACT(data, '$.address.city')) = ? ORDER BY  JSON_UNQUOTE(JSON_EXTRACT(data, '$.tags[0]'))  DESC`},
+		{qb.DialectSqlite, `SELECT id ,  json_extract(data, '$.address.city')  AS city FROM users WHERE  json_extract(data, '$.address.city') = ? ORDER BY  json_extract(data, '$.tags[0]')  DESC`},
+		{qb.DialectMssql, `SELECT id ,  JSON_VALUE(data, '$.address.city')  AS city FROM users WHERE  JSON_VALUE(data, '$.address.city') = @p1 ORDER BY  JSON_VALUE(data, '$.tags[0]')  DESC`},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.dialect), func(t *testing.T) {
+			q := query(tt.dialect)
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, []interface{}{"Oslo"}, q.Args())
+		})
+	}
+
+	t.Run("values", func(t *testing.T) {
+		p := qb.JSON("data").Path("it's", `a "b"`)
+		q := qb.WithDialectPQ().SelectColumn("?, ?", p.Expr(), qb.JSON("data").Text()).From("t")
+		require.Equal(t, `SELECT  data->'it''s'->'a "b"' , data #>> '{}' FROM t`, q.SQL())
+		require.Equal(t, `data->'it''s'->'a "b"'`, p.String())
+
+		q = q.DialectOption(qb.DialectMssql)
+		require.Equal(t, `SELECT  JSON_QUERY(data, '$."it''s"."a \"b\""') , JSON_VALUE(data, '$') FROM t`, q.SQL())
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		q := qb.Strict().SelectColumn("? AS city", city.Text()).From("users").OrderByExpr("?", city.Text())
+		require.Equal(t, `SELECT  data->'address'->>'city'  AS city FROM users ORDER BY  data->'address'->>'city'`, q.SQL())
+
+		q = qb.Strict().Select("*").From("users").Where(qb.Pred("? = ?", qb.JSON("data; DROP TABLE users").Text(), "Oslo"))
+		require.EqualError(t, recoverError(func() { q.SQL() }), `qb: strict: "data; DROP TABLE users" is not a valid column; use qb.Raw to write raw SQL`)
+
+		q = qb.Strict().Select(city.String()).From("users")
+		require.Error(t, recoverError(func() { q.SQL() }))
+	})
+}
+
+func TestJSON_Predicates(t *testing.T) {
+	address := qb.JSON("data").Path("address")
+	query := func(d qb.Dialect) qb.Query {
+		return qb.DialectOption(d).Select("*").From("users").
+			Where(address.Contains(map[string]string{"city": "Oslo"})).
+			Where(address.HasKey("zip"))
+	}
+
+	tests := []struct {
+		dialect qb.Dialect
+		expr    string
+		args    []interface{}
+		err     string
+	}{
+		{
+			dialect: qb.DialectPq,
+			expr:    `SELECT * FROM users WHERE data->'address' @> $1 ::jsonb AND data->'address' ? $2`,
+			args:    []interface{}{`{"city":"Oslo"}`, "zip"},
+		},
+		{
+			dialect: qb.DialectMysql,
+			expr:    `SELECT * FROM users WHERE JSON_CONTAINS(data, ? , ? ) AND JSON_CONTAINS_PATH(data, 'one', ? )`,
+			args:    []interface{}{`{"city":"Oslo"}`, "$.address", "$.address.zip"},
+		},
+		{
+			dialect: qb.DialectSqlite,
+			err:     "qb: JSON containment is not supported in the sqlite dialect",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.dialect), func(t *testing.T) {
+			q := query(tt.dialect)
+			if tt.err != "" {
+				require.EqualError(t, recoverError(func() { q.SQL() }), tt.err)
+				return
+			}
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+
+	t.Run("HasKey", func(t *testing.T) {
+		data := qb.JSON("data")
+		q := qb.WithDialectSqlite().Select("*").From("t").Where(data.HasKey("a"))
+		require.Equal(t, `SELECT * FROM t WHERE json_type(data, ? ) IS NOT NULL`, q.SQL())
+		require.Equal(t, []interface{}{"$.a"}, q.Args())
+
+		q = q.DialectOption(qb.DialectMssql)
+		require.Equal(t, `SELECT * FROM t WHERE JSON_PATH_EXISTS(data, @p1 ) = 1`, q.SQL())
+
+		q = q.DialectOption(qb.DialectGoracle)
+		require.Equal(t, `SELECT * FROM t WHERE JSON_EXISTS(data, '$.a')`, q.SQL())
+		require.Equal(t, []interface{}{}, q.Args())
+
+		q = q.DialectOption(qb.DialectDefault)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: JSON key existence is not supported in the default dialect")
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		q := qb.WithDialectPQ().Select("*").From("t").Where(qb.JSON("data").Contains(func() {}))
+		_, _, err := q.TryBuild()
+		require.EqualError(t, err, "qb: cannot marshal JSON value: json: unsupported type: func()")
+	})
+
+	t.Run("escaped question mark", func(t *testing.T) {
+		q := qb.WithDialectPQ().Select("*").From("t").Where(qb.Pred("data ?? 'a' AND data ??| ?", []string{"b"}))
+		require.Equal(t, `SELECT * FROM t WHERE data ? 'a' AND data ?| $1`, q.SQL())
+	})
+}
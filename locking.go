@@ -0,0 +1,263 @@
+package qb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A tableRef records where a table expression was written into a query, so
+// that clauses which follow it can annotate the table.
+type tableRef struct {
+	at    int
+	sql   string
+	names []string
+	// t is the clause in which the table was written.
+	t expressionType
+}
+
+// writeTable writes a table expression and records it as a table of the
+// query. A list of tables separated by commas in a FROM or USING clause is
+// recorded table by table.
+func (q Query) writeTable(table string) Query {
+	if toks, err := lexSQL(table, DialectDefault); err == nil && (q.last == fromExpr || q.last == usingExpr) {
+		if parts := splitTokens(toks, ","); len(parts) > 1 {
+			for i, part := range parts {
+				if i > 0 {
+					q.w.WriteSQL(",")
+				}
+				q = q.writeTable(tokensText(part))
+			}
+			return q
+		}
+	}
+
+	tables1 := make([]tableRef, 0, len(q.tables)+1)
+	tables1 = append(tables1, q.tables...)
+	q.tables = append(tables1, tableRef{
+		at:    q.w.Len(),
+		sql:   table,
+		names: tableNames(table),
+		t:     q.last,
+	})
+
+	q.w.writeChecked(checkTable, table)
+	return q
+}
+
+// tableNames returns the names by which a table expression such as
+// `t1 AS "a"` may be referred to.
+func tableNames(table string) []string {
+	var names []string
+	for _, field := range strings.Fields(strings.Replace(table, `"`, "", -1)) {
+		if !strings.EqualFold(field, "AS") {
+			names = append(names, field)
+		}
+	}
+	return names
+}
+
+// locking is the fragment written by the row locking methods.
+type locking struct {
+	strength string
+	of       []string
+	wait     string
+}
+
+func (l locking) appliesTo(names []string) bool {
+	if len(l.of) == 0 {
+		return true
+	}
+
+	for _, of := range l.of {
+		for _, name := range names {
+			if of == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l locking) writeTo(w *sqlWriter, d Dialect) error {
+	strength := l.strength
+	switch d {
+	case DialectMssql:
+		// mssql has no locking clause; see lockedTable for its table hints.
+		return nil
+	case DialectSqlite:
+		// sqlite locks the whole database instead.
+		return nil
+	case DialectMysql:
+		switch strength {
+		case "NO KEY UPDATE":
+			strength = "UPDATE"
+		case "KEY SHARE":
+			strength = "SHARE"
+		}
+	case DialectGoracle:
+		// Oracle only locks rows for update.
+		if strength != "UPDATE" {
+			return fmt.Errorf("qb: FOR %s is not supported in the %s dialect", strength, dialectName(d))
+		}
+	}
+
+	w.WriteSQL("FOR " + strength)
+	if len(l.of) > 0 {
+		w.WriteSQL("OF", strings.Join(l.of, ", "))
+	}
+
+	if l.wait != "" {
+		w.WriteSQL(l.wait)
+	}
+
+	return nil
+}
+
+// lockedTable is a table expression in a query with row locking clauses. In
+// the mssql dialect, the clauses which apply to the table are rendered as
+// table hints.
+type lockedTable struct {
+	sql   string
+	names []string
+	locks []locking
+}
+
+func (t lockedTable) writeTo(w *sqlWriter, d Dialect) error {
+	w.WriteSQL(t.sql)
+	if d != DialectMssql {
+		return nil
+	}
+
+	var hints []string
+	addHint := func(hint string) {
+		for _, h := range hints {
+			if h == hint {
+				return
+			}
+		}
+		hints = append(hints, hint)
+	}
+
+	for _, l := range t.locks {
+		if !l.appliesTo(t.names) {
+			continue
+		}
+
+		switch l.strength {
+		case "UPDATE", "NO KEY UPDATE":
+			addHint("UPDLOCK")
+		default:
+			addHint("HOLDLOCK")
+		}
+
+		switch l.wait {
+		case "NOWAIT":
+			addHint("NOWAIT")
+		case "SKIP LOCKED":
+			addHint("READPAST")
+		}
+	}
+
+	if len(hints) > 0 {
+		w.WriteSQL("WITH (" + strings.Join(hints, ", ") + ")")
+	}
+
+	return nil
+}
+
+// lockRows writes l as a new locking clause, or replaces the current locking
+// clause with l.
+func (q Query) lockRows(l locking, replace bool) Query {
+	for _, ref := range q.tables {
+		if ref.t != fromExpr && ref.t != joinExpr {
+			continue
+		}
+
+		t, ok := q.w.fragmentAt(ref.at).(lockedTable)
+		if !ok {
+			t = lockedTable{sql: ref.sql, names: ref.names}
+		}
+
+		locks := make([]locking, 0, len(t.locks)+1)
+		locks = append(locks, t.locks...)
+		if replace {
+			locks[len(locks)-1] = l
+		} else {
+			locks = append(locks, l)
+		}
+
+		t.locks = locks
+		q.w.replace(ref.at, t)
+	}
+
+	if replace {
+		q.w.replace(q.w.Len()-1, l)
+	} else {
+		q.w.WriteFragment(l)
+	}
+
+	q.last = lockingExpr
+	return q
+}
+
+func (q Query) lastLocking(method string) locking {
+	l, ok := q.w.lastFragment().(locking)
+	if !ok || q.last != lockingExpr {
+		panic("qb: " + method + " must follow a row locking clause")
+	}
+	return l
+}
+
+// Appends a FOR UPDATE clause. In the mssql dialect, this is rendered as an
+// UPDLOCK hint on the locked tables.
+//  ... FOR UPDATE
+func (q Query) ForUpdate() Query {
+	return q.lockRows(locking{strength: "UPDATE"}, false)
+}
+
+// Appends a FOR NO KEY UPDATE clause. This is FOR UPDATE in the mysql dialect,
+// and is not supported in the goracle dialect.
+//  ... FOR NO KEY UPDATE
+func (q Query) ForNoKeyUpdate() Query {
+	return q.lockRows(locking{strength: "NO KEY UPDATE"}, false)
+}
+
+// Appends a FOR SHARE clause. In the mssql dialect, this is rendered as a
+// HOLDLOCK hint on the locked tables. It is not supported in the goracle
+// dialect.
+//  ... FOR SHARE
+func (q Query) ForShare() Query {
+	return q.lockRows(locking{strength: "SHARE"}, false)
+}
+
+// Appends a FOR KEY SHARE clause. This is FOR SHARE in the mysql dialect, and
+// is not supported in the goracle dialect.
+//  ... FOR KEY SHARE
+func (q Query) ForKeyShare() Query {
+	return q.lockRows(locking{strength: "KEY SHARE"}, false)
+}
+
+// Restricts the preceding row locking clause to the given tables.
+//  ... FOR UPDATE OF table0[, table1[, ...]]
+func (q Query) Of(tables ...string) Query {
+	l := q.lastLocking("Of")
+	l.of = append(append([]string{}, l.of...), tables...)
+	return q.lockRows(l, true)
+}
+
+// Adds NOWAIT to the preceding row locking clause.
+//  ... FOR UPDATE NOWAIT
+func (q Query) NoWait() Query {
+	l := q.lastLocking("NoWait")
+	l.wait = "NOWAIT"
+	return q.lockRows(l, true)
+}
+
+// Adds SKIP LOCKED to the preceding row locking clause. In the mssql dialect,
+// this is rendered as a READPAST hint.
+//  ... FOR UPDATE SKIP LOCKED
+func (q Query) SkipLocked() Query {
+	l := q.lastLocking("SkipLocked")
+	l.wait = "SKIP LOCKED"
+	return q.lockRows(l, true)
+}
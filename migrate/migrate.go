@@ -0,0 +1,430 @@
+// Package migrate applies versioned schema migrations built with qb.
+//
+// Migrations are registered with a Migrator either as Go functions returning
+// a qb.Query, or as .sql files. The versions which have been applied are
+// recorded in a bookkeeping table, schema_migrations by default.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tetratom/qb"
+)
+
+// Latest is the target version of the newest registered migration.
+const Latest int64 = math.MaxInt64
+
+type Migration struct {
+	Version int64
+	Name    string
+
+	// Up applies the migration. Down reverts it, and may be nil if the
+	// migration cannot be reverted.
+	Up   func() qb.Query
+	Down func() qb.Query
+}
+
+type Migrator struct {
+	DB      *sql.DB
+	Dialect qb.Dialect
+
+	// Table is the name of the bookkeeping table. It is schema_migrations if
+	// empty.
+	Table string
+
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialect qb.Dialect) *Migrator {
+	return &Migrator{DB: db, Dialect: dialect}
+}
+
+func (m *Migrator) table() string {
+	if m.Table == "" {
+		return "schema_migrations"
+	}
+	return m.Table
+}
+
+// Register adds migrations to the migrator. Versions must be unique.
+func (m *Migrator) Register(migrations ...Migration) error {
+	for _, mig := range migrations {
+		if mig.Up == nil {
+			return fmt.Errorf("migrate: migration %d has no up migration", mig.Version)
+		}
+
+		for _, other := range m.migrations {
+			if other.Version == mig.Version {
+				return fmt.Errorf("migrate: duplicate migration version %d", mig.Version)
+			}
+		}
+
+		m.migrations = append(m.migrations, mig)
+	}
+
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return nil
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// RegisterFS adds the migrations in the root directory of fsys, such as an
+// embed.FS. Migrations are read from files named VERSION_NAME.up.sql and
+// VERSION_NAME.down.sql, of which the latter is optional. Other files are
+// ignored.
+func (m *Migrator) RegisterFS(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	byVersion := map[int64]*Migration{}
+	var versions []int64
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate: %s: %v", entry.Name(), err)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+			versions = append(versions, version)
+		}
+
+		q := rawQuery(string(b))
+		if match[3] == "up" {
+			mig.Up = func() qb.Query { return q }
+		} else {
+			mig.Down = func() qb.Query { return q }
+		}
+	}
+
+	for _, version := range versions {
+		if err := m.Register(*byVersion[version]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawQuery returns a query consisting of the given SQL verbatim.
+func rawQuery(sql string) qb.Query {
+	return qb.Append("?", qb.Lit("%s", sql))
+}
+
+// A step applies or reverts one migration.
+type step struct {
+	migration Migration
+	up        bool
+}
+
+func (s step) query() qb.Query {
+	if s.up {
+		return s.migration.Up()
+	}
+	return s.migration.Down()
+}
+
+func (m *Migrator) bookkeeping(s step) qb.Query {
+	if s.up {
+		return qb.
+			InsertInto(m.table(), "version", "name").
+			Values(s.migration.Version, s.migration.Name)
+	}
+
+	return qb.
+		DeleteFrom(m.table()).
+		Where(qb.And("version = ?", s.migration.Version))
+}
+
+// createTable returns the statement which creates the bookkeeping table.
+// The table is only created if it does not exist, which in the goracle
+// dialect, which has no IF NOT EXISTS, is checked by the caller.
+func (m *Migrator) createTable() qb.Query {
+	t := qb.CreateTable(m.table())
+	if m.Dialect != qb.DialectGoracle {
+		t = t.IfNotExists()
+	}
+
+	return t.
+		Column("version", qb.TypeBigInt, qb.ConstraintPrimaryKey).
+		Column("name", qb.TypeVarchar(255), qb.ConstraintNotNull).
+		Column("applied_at", qb.TypeTimestamp, qb.ConstraintNotNull, qb.ConstraintDefault("CURRENT_TIMESTAMP")).
+		Query().
+		DialectOption(m.Dialect)
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// exec runs a statement, unless it renders to no SQL, such as the query of a
+// migration without statements.
+func exec(ctx context.Context, db execQueryer, q qb.Query) error {
+	s, args, err := q.TryBuild()
+	if err != nil {
+		return err
+	}
+
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+	_, err = db.ExecContext(ctx, s, args...)
+	return err
+}
+
+func query(ctx context.Context, db execQueryer, q qb.Query) (*sql.Rows, error) {
+	s, args, err := q.TryBuild()
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(ctx, s, args...)
+}
+
+// tableExists returns a query of a row if the bookkeeping table exists.
+func (m *Migrator) tableExists() qb.Query {
+	var q qb.Query
+	switch m.Dialect {
+	case qb.DialectSqlite:
+		q = qb.Select("1").
+			From("sqlite_master").
+			Where(qb.And("type = 'table'").And("name = ?", m.table()))
+	case qb.DialectGoracle:
+		q = qb.Select("1").
+			From("user_tables").
+			Where(qb.And("table_name = UPPER(?)", m.table()))
+	default:
+		schema := "current_schema()"
+		switch m.Dialect {
+		case qb.DialectMysql:
+			schema = "DATABASE()"
+		case qb.DialectMssql:
+			schema = "SCHEMA_NAME()"
+		}
+
+		q = qb.Select("1").
+			From("information_schema.tables").
+			Where(qb.And("table_schema = "+schema).And("table_name = ?", m.table()))
+	}
+	return q.DialectOption(m.Dialect)
+}
+
+func (m *Migrator) hasTable(ctx context.Context, db execQueryer) (bool, error) {
+	rows, err := query(ctx, db, m.tableExists())
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	exists := rows.Next()
+	return exists, rows.Err()
+}
+
+// Applied returns the applied migration versions in ascending order.
+func (m *Migrator) Applied(ctx context.Context) ([]int64, error) {
+	return m.applied(ctx, m.DB)
+}
+
+// applied returns the applied migration versions, of which there are none if
+// the bookkeeping table does not exist.
+func (m *Migrator) applied(ctx context.Context, db execQueryer) ([]int64, error) {
+	exists, err := m.hasTable(ctx, db)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	q := qb.
+		Select("version").
+		From(m.table()).
+		OrderBy("version").
+		DialectOption(m.Dialect)
+
+	rows, err := query(ctx, db, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// plan returns the steps which migrate the database to the target version.
+func (m *Migrator) plan(ctx context.Context, db execQueryer, target int64) ([]step, error) {
+	applied, err := m.applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	isApplied := map[int64]bool{}
+	for _, version := range applied {
+		isApplied[version] = true
+	}
+
+	var steps []step
+	for _, mig := range m.migrations {
+		if mig.Version <= target && !isApplied[mig.Version] {
+			steps = append(steps, step{migration: mig, up: true})
+		}
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version > target && isApplied[mig.Version] {
+			if mig.Down == nil {
+				return nil, fmt.Errorf("migrate: migration %d has no down migration", mig.Version)
+			}
+			steps = append(steps, step{migration: mig, up: false})
+		}
+	}
+
+	return steps, nil
+}
+
+// Plan returns the statements which Migrate would run to migrate the database
+// to the target version, without running them. The database is not
+// modified; if the bookkeeping table does not exist, no migration is applied.
+func (m *Migrator) Plan(ctx context.Context, target int64) (qb.Query, error) {
+	steps, err := m.plan(ctx, m.DB, target)
+	if err != nil {
+		return qb.Query{}, err
+	}
+
+	qs := make([]qb.Query, 0, 2*len(steps))
+	for _, s := range steps {
+		qs = append(qs, s.query(), m.bookkeeping(s))
+	}
+	return qb.Multiple(qs...).DialectOption(m.Dialect), nil
+}
+
+// Migrate applies or reverts migrations until the database is at the target
+// version. Every migration is run in a transaction of its own, together with
+// its bookkeeping, and the bookkeeping table is created if it does not exist.
+// Concurrent runners are excluded with an advisory lock in the pq and mysql
+// dialects.
+func (m *Migrator) Migrate(ctx context.Context, target int64) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists, err := m.hasTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := exec(ctx, conn, m.createTable()); err != nil {
+			return err
+		}
+	}
+
+	steps, err := m.plan(ctx, conn, target)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range steps {
+		err := qb.InTx(ctx, conn, &qb.TxOptions{MaxAttempts: 1}, func(tx *qb.Tx) error {
+			if err := exec(ctx, tx.Tx, s.query().DialectOption(m.Dialect)); err != nil {
+				return err
+			}
+
+			return exec(ctx, tx.Tx, m.bookkeeping(s).DialectOption(m.Dialect))
+		})
+		if err != nil {
+			return fmt.Errorf("migrate: migration %d (%s): %v", s.migration.Version, s.migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.Migrate(ctx, Latest)
+}
+
+// Down reverts the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	applied, err := m.Applied(ctx)
+	if err != nil || len(applied) == 0 {
+		return err
+	}
+
+	target := int64(math.MinInt64)
+	if len(applied) > 1 {
+		target = applied[len(applied)-2]
+	}
+	return m.Migrate(ctx, target)
+}
+
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("migrate:" + m.table()))
+	return int64(h.Sum64())
+}
+
+// lock takes a session-level advisory lock on conn, where the dialect
+// supports it.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	var lock, unlock qb.Query
+	switch m.Dialect {
+	case qb.DialectPq:
+		lock = qb.SelectColumn("pg_advisory_lock(?)", m.lockKey())
+		unlock = qb.SelectColumn("pg_advisory_unlock(?)", m.lockKey())
+	case qb.DialectMysql:
+		name := "migrate:" + m.table()
+		lock = qb.SelectColumn("GET_LOCK(?, -1)", name)
+		unlock = qb.SelectColumn("RELEASE_LOCK(?)", name)
+	default:
+		return func() {}, nil
+	}
+
+	lock, unlock = lock.DialectOption(m.Dialect), unlock.DialectOption(m.Dialect)
+	if err := exec(ctx, conn, lock); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = exec(context.Background(), conn, unlock)
+	}, nil
+}
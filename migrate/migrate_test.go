@@ -0,0 +1,199 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"github.com/tetratom/qb"
+	"github.com/tetratom/qb/migrate"
+)
+
+// openDB opens an empty SQLite database.
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// schema returns the names of the tables and of the columns of users in db.
+func schema(t *testing.T, db *sql.DB) []string {
+	rows, err := db.Query(`
+		SELECT name FROM sqlite_master WHERE type = 'table'
+		UNION ALL
+		SELECT 'users.' || name FROM pragma_table_info('users')
+		ORDER BY 1`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+	return names
+}
+
+// fakeConnector is a database/sql driver connection which fails every
+// statement with an error of its SQL.
+type fakeConnector struct{}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+func (c fakeConnector) Prepare(query string) (driver.Stmt, error)    { return nil, errors.New(query) }
+func (c fakeConnector) Close() error                                 { return nil }
+func (c fakeConnector) Begin() (driver.Tx, error)                    { return nil, errors.New("BEGIN") }
+
+func migrations() []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version: 2,
+			Name:    "add_email",
+			Up: func() qb.Query {
+				return qb.AlterTable("users").AddColumn("email", qb.TypeText).Query()
+			},
+			Down: func() qb.Query {
+				return qb.AlterTable("users").DropColumn("email").Query()
+			},
+		},
+		{
+			Version: 1,
+			Name:    "create_users",
+			Up: func() qb.Query {
+				return qb.CreateTable("users").Column("id", qb.TypeBigSerial, qb.ConstraintPrimaryKey).Query()
+			},
+			Down: func() qb.Query {
+				return qb.DropTable("users").Query()
+			},
+		},
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("up and down", func(t *testing.T) {
+		db := openDB(t)
+		m := migrate.New(db, qb.DialectSqlite)
+		require.NoError(t, m.Register(migrations()...))
+
+		require.NoError(t, m.Up(ctx))
+		require.Equal(t, []string{"schema_migrations", "users", "users.email", "users.id"}, schema(t, db))
+
+		applied, err := m.Applied(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 2}, applied)
+
+		require.NoError(t, m.Down(ctx))
+		require.Equal(t, []string{"schema_migrations", "users", "users.id"}, schema(t, db))
+
+		require.NoError(t, m.Down(ctx))
+		require.Equal(t, []string{"schema_migrations"}, schema(t, db))
+
+		applied, err = m.Applied(ctx)
+		require.NoError(t, err)
+		require.Empty(t, applied)
+	})
+
+	t.Run("failed migration", func(t *testing.T) {
+		db := openDB(t)
+		m := migrate.New(db, qb.DialectSqlite)
+		require.NoError(t, m.Register(migrations()...))
+		require.NoError(t, m.Register(migrate.Migration{
+			Version: 3,
+			Name:    "add_email_again",
+			Up: func() qb.Query {
+				return qb.AlterTable("users").AddColumn("email", qb.TypeText).Query()
+			},
+		}))
+
+		err := m.Up(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "migrate: migration 3 (add_email_again): ")
+
+		applied, err := m.Applied(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 2}, applied)
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		db := openDB(t)
+		m := migrate.New(db, qb.DialectSqlite)
+		require.NoError(t, m.Register(migrations()...))
+
+		q, err := m.Plan(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t,
+			"CREATE TABLE users ( id INTEGER PRIMARY KEY ) ; INSERT INTO schema_migrations ( version , name ) VALUES ( ? , ? ) ;",
+			q.SQL())
+		require.Empty(t, schema(t, db))
+
+		require.NoError(t, m.Migrate(ctx, 1))
+
+		q, err = m.Plan(ctx, migrate.Latest)
+		require.NoError(t, err)
+		require.Equal(t,
+			"ALTER TABLE users ADD COLUMN email TEXT ; INSERT INTO schema_migrations ( version , name ) VALUES ( ? , ? ) ;",
+			q.SQL())
+		require.Equal(t, []interface{}{int64(2), "add_email"}, q.Args())
+
+		q, err = m.Plan(ctx, 0)
+		require.NoError(t, err)
+		require.Equal(t,
+			"DROP TABLE users ; DELETE FROM schema_migrations WHERE version = ? ;",
+			q.SQL())
+		require.Equal(t, []string{"schema_migrations", "users", "users.id"}, schema(t, db))
+	})
+
+	t.Run("duplicate version", func(t *testing.T) {
+		m := migrate.New(nil, qb.DialectDefault)
+		require.NoError(t, m.Register(migrations()...))
+		require.Error(t, m.Register(migrations()[0]))
+	})
+
+	t.Run("register fs", func(t *testing.T) {
+		db := openDB(t)
+		m := migrate.New(db, qb.DialectSqlite)
+		require.NoError(t, m.RegisterFS(fstest.MapFS{
+			"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER)")},
+			"0001_create_users.down.sql": {Data: []byte("DROP TABLE users")},
+			"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT")},
+			"README.md":                  {Data: []byte("ignored")},
+		}))
+
+		require.NoError(t, m.Up(ctx))
+		require.Equal(t, []string{"schema_migrations", "users", "users.email", "users.id"}, schema(t, db))
+
+		err := m.Down(ctx)
+		require.EqualError(t, err, "migrate: migration 2 has no down migration")
+	})
+
+	t.Run("table exists", func(t *testing.T) {
+		tests := []struct {
+			dialect qb.Dialect
+			sql     string
+		}{
+			{qb.DialectPq, "SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1"},
+			{qb.DialectMysql, "SELECT 1 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?"},
+			{qb.DialectMssql, "SELECT 1 FROM information_schema.tables WHERE table_schema = SCHEMA_NAME() AND table_name = @p1"},
+			{qb.DialectGoracle, "SELECT 1 FROM user_tables WHERE table_name = UPPER( :1 )"},
+		}
+
+		for _, tt := range tests {
+			db := sql.OpenDB(fakeConnector{})
+			m := migrate.New(db, tt.dialect)
+			_, err := m.Applied(ctx)
+			require.EqualError(t, err, tt.sql)
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package qb
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// A paginationTerm is the value of a LIMIT or an OFFSET.
+type paginationTerm struct {
+	set   bool
+	all   bool
+	n     int64
+	arg   bool
+	value interface{}
+}
+
+func (t paginationTerm) writeTo(w *sqlWriter) {
+	if t.arg {
+		w.WriteValue(t.value)
+	} else {
+		w.WriteSQL(strconv.FormatInt(t.n, 10))
+	}
+}
+
+// pagination is the fragment written by Limit and Offset. The clause is kept
+// whole so that it can be rendered in the order and syntax of each dialect.
+// ordered is whether the query has an ORDER BY clause, and top is set when the
+// query is rendered in the mssql dialect.
+type pagination struct {
+	limit   paginationTerm
+	offset  paginationTerm
+	ordered bool
+	top     bool
+}
+
+var errPaginationUnordered = errors.New(
+	"qb: OFFSET ... FETCH requires an ORDER BY clause in the mssql dialect")
+
+func (p pagination) writeTo(w *sqlWriter, d Dialect) error {
+	switch d {
+	case DialectMssql, DialectGoracle:
+		hasLimit := p.limit.set && !p.limit.all
+		if !hasLimit && !p.offset.set {
+			return nil
+		}
+
+		if d == DialectMssql && p.top {
+			return nil
+		}
+
+		if d == DialectMssql && !p.ordered {
+			return errPaginationUnordered
+		}
+
+		// FETCH must follow an OFFSET clause in mssql.
+		if p.offset.set || d == DialectMssql {
+			w.WriteSQL("OFFSET")
+			if p.offset.set {
+				p.offset.writeTo(w)
+			} else {
+				w.WriteSQL("0")
+			}
+			w.WriteSQL("ROWS")
+		}
+
+		if hasLimit {
+			w.WriteSQL("FETCH NEXT")
+			p.limit.writeTo(w)
+			w.WriteSQL("ROWS ONLY")
+		}
+	case DialectMysql, DialectSqlite:
+		if p.limit.set && !p.limit.all {
+			w.WriteSQL("LIMIT")
+			p.limit.writeTo(w)
+		} else if p.offset.set {
+			// Neither dialect allows an OFFSET without a LIMIT.
+			if d == DialectMysql {
+				w.WriteSQL("LIMIT", "18446744073709551615")
+			} else {
+				w.WriteSQL("LIMIT", "-1")
+			}
+		}
+
+		if p.offset.set {
+			w.WriteSQL("OFFSET")
+			p.offset.writeTo(w)
+		}
+	default:
+		if p.limit.set {
+			w.WriteSQL("LIMIT")
+			if p.limit.all {
+				w.WriteSQL("ALL")
+			} else {
+				p.limit.writeTo(w)
+			}
+		}
+
+		if p.offset.set {
+			w.WriteSQL("OFFSET")
+			p.offset.writeTo(w)
+		}
+	}
+
+	return nil
+}
+
+func (p pagination) mapArgs(f func(v interface{}) interface{}) fragment {
+	if p.limit.arg {
+		p.limit.value = f(p.limit.value)
+	}
+
+	if p.offset.arg {
+		p.offset.value = f(p.offset.value)
+	}
+	return p
+}
+
+// paginating adds the given term to the pagination fragment at the end of the
+// query, or starts a new one.
+func (q Query) paginating(t expressionType, term paginationTerm) Query {
+	p, ok := q.w.lastFragment().(pagination)
+	extend := ok && (q.last == limitExpr || q.last == offsetExpr)
+	if !extend {
+		p = pagination{}
+	}
+
+	p = p.with(t, term)
+	p.ordered = q.ordered
+	if extend {
+		q.w.replace(q.w.Len()-1, p)
+	} else {
+		q.w.WriteFragment(p)
+	}
+
+	q.last = t
+	return q
+}
+
+func (p pagination) with(t expressionType, term paginationTerm) pagination {
+	if t == limitExpr {
+		p.limit = term
+	} else {
+		p.offset = term
+	}
+	return p
+}
+
+// Appends a LIMIT clause. The clause is rendered according to the dialect:
+//  ... LIMIT limit
+//  ... OFFSET 0 ROWS FETCH NEXT limit ROWS ONLY (mssql)
+//  SELECT TOP (limit) ... (mssql, without ORDER BY)
+//  ... FETCH NEXT limit ROWS ONLY (goracle)
+func (q Query) Limit(limit int64) Query {
+	return q.paginating(limitExpr, paginationTerm{set: true, n: limit})
+}
+
+// Appends a LIMIT clause with the limit passed as an argument.
+//  ... LIMIT ?
+func (q Query) LimitArg(limit interface{}) Query {
+	return q.paginating(limitExpr, paginationTerm{set: true, arg: true, value: limit})
+}
+
+// Appends a LIMIT ALL clause. This is omitted in dialects without LIMIT ALL.
+func (q Query) LimitAll() Query {
+	return q.paginating(limitExpr, paginationTerm{set: true, all: true})
+}
+
+// Appends an OFFSET clause. The clause is rendered according to the dialect:
+//  ... OFFSET offset
+//  ... OFFSET offset ROWS (mssql, goracle)
+// The mssql dialect requires the query to be ordered.
+func (q Query) Offset(offset int64) Query {
+	return q.paginating(offsetExpr, paginationTerm{set: true, n: offset})
+}
+
+// Appends an OFFSET clause with the offset passed as an argument.
+//  ... OFFSET ?
+func (q Query) OffsetArg(offset interface{}) Query {
+	return q.paginating(offsetExpr, paginationTerm{set: true, arg: true, value: offset})
+}
+
+// topPagination prepares the pagination fragments among the tokens of a
+// writer for the mssql dialect, where OFFSET ... FETCH requires an ORDER BY.
+// It rewrites a LIMIT without OFFSET of a SELECT which is not ordered into a
+// TOP clause.
+func topPagination(tokens []token) []token {
+	paginated := false
+	for _, t := range tokens {
+		if _, ok := t.frag.(pagination); ok {
+			paginated = true
+		}
+	}
+	if !paginated {
+		return tokens
+	}
+
+	out := make([]token, 0, len(tokens)+2)
+	for _, t := range tokens {
+		p, ok := t.frag.(pagination)
+		if !ok {
+			out = append(out, t)
+			continue
+		}
+
+		if !p.ordered && !p.offset.set && p.limit.set && !p.limit.all {
+			if at := selectAt(out); at >= 0 {
+				p.top = true
+				out = insertTop(out, at, p.limit)
+			}
+		}
+		out = append(out, token{frag: p})
+	}
+	return out
+}
+
+// selectAt returns the index of the SELECT keyword of the tokens of a query,
+// or -1 if there is none, or if the query combines several SELECTs.
+func selectAt(tokens []token) int {
+	at := -1
+	for i, t := range tokens {
+		if t.frag != nil {
+			continue
+		}
+
+		switch {
+		case isKeyword(t.sql, "UNION"), isKeyword(t.sql, "INTERSECT"), isKeyword(t.sql, "EXCEPT"):
+			return -1
+		case at < 0 && strings.EqualFold(strings.TrimSpace(t.sql), "SELECT"):
+			at = i
+		}
+	}
+	return at
+}
+
+// isKeyword reports whether s starts with the keyword kw.
+func isKeyword(s, kw string) bool {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	return s == kw || strings.HasPrefix(s, kw+" ")
+}
+
+// insertTop inserts a TOP clause after the SELECT keyword at the given index,
+// and after DISTINCT if it follows.
+func insertTop(tokens []token, at int, limit paginationTerm) []token {
+	out := make([]token, 0, len(tokens)+2)
+	out = append(out, tokens[:at+1]...)
+	rest := tokens[at+1:]
+	if len(rest) > 0 && rest[0].frag == nil && isKeyword(rest[0].sql, "DISTINCT") {
+		t := rest[0]
+		t.sql = strings.TrimSpace(strings.TrimSpace(t.sql)[len("DISTINCT"):])
+		out = append(out, token{sql: "DISTINCT"})
+		rest = rest[1:]
+		if t.sql != "" {
+			rest = append([]token{t}, rest...)
+		}
+	}
+
+	out = append(out, token{frag: topClause{limit}})
+	return append(out, rest...)
+}
+
+// topClause is the TOP clause of a SELECT in the mssql dialect.
+type topClause struct {
+	limit paginationTerm
+}
+
+func (c topClause) writeTo(w *sqlWriter, d Dialect) error {
+	if c.limit.arg {
+		w.WriteSQL("TOP (")
+		w.WriteValue(c.limit.value)
+		w.WriteSQL(")")
+	} else {
+		w.WriteSQL("TOP (" + strconv.FormatInt(c.limit.n, 10) + ")")
+	}
+	return nil
+}
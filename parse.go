@@ -0,0 +1,1135 @@
+package qb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Placeholder is an argument of a parsed query which has not been bound yet.
+// It stands for the n-th argument of the SQL that was parsed, counting from 1.
+// Named placeholders, such as :name in the goracle dialect and @name in the
+// mssql dialect, are numbered after the positional ones, in the order in
+// which they first appear.
+// Use WithArgs to bind the placeholders of a query to their values.
+type Placeholder int
+
+// Binds the placeholders of a parsed query to the given arguments. The n-th
+// argument is bound to every use of the n-th placeholder; other arguments of
+// the query are left as they are.
+func (q Query) WithArgs(args ...interface{}) Query {
+	q.w = q.w.mapArgs(func(v interface{}) interface{} {
+		p, ok := v.(Placeholder)
+		if !ok {
+			return v
+		}
+
+		if p < 1 || int(p) > len(args) {
+			panic(fmt.Errorf("qb: no argument for placeholder %d", p))
+		}
+		return args[p-1]
+	})
+	return q
+}
+
+// Parse parses a SELECT, INSERT, UPDATE or DELETE statement written in the
+// given dialect into a Query, which can then be extended with the builder
+// methods like any other query:
+//  q, err := qb.Parse(`SELECT * FROM users WHERE org_id = $1`, qb.DialectPq)
+//  q = q.Where(qb.And("name = ?", name)).OrderBy("id").Limit(10)
+//  q = q.WithArgs(orgID)
+//
+// The placeholders of the statement are kept in place, and appear in Args()
+// as Placeholder values until they are bound with WithArgs. Arguments added
+// by later builder calls follow them in Args().
+//
+// As with queries which are built, clauses must be added in the order in
+// which they appear in SQL: a statement ending in ORDER BY can be given a
+// LIMIT, but not another WHERE clause. Several statements separated by
+// semicolons are parsed into a Multiple query.
+func Parse(sql string, dialect Dialect) (Query, error) {
+	toks, err := lexSQL(sql, dialect)
+	if err != nil {
+		return Query{}, err
+	}
+
+	depth := 0
+	for _, t := range toks {
+		if t.is("(") {
+			depth++
+		} else if t.is(")") {
+			depth--
+		}
+
+		if depth < 0 {
+			break
+		}
+	}
+
+	if depth != 0 {
+		return Query{}, fmt.Errorf("qb: parse: unbalanced parentheses")
+	}
+
+	var qs []Query
+	for _, stmt := range splitTokens(toks, ";") {
+		if len(stmt) == 0 {
+			continue
+		}
+
+		q, err := parseQuery(stmt, dialect)
+		if err != nil {
+			return Query{}, err
+		}
+		qs = append(qs, q)
+	}
+
+	switch len(qs) {
+	case 0:
+		return Query{}, fmt.Errorf("qb: parse: empty statement")
+	case 1:
+		return qs[0], nil
+	default:
+		return Multiple(qs...).DialectOption(dialect), nil
+	}
+}
+
+type sqlTokenKind int
+
+const (
+	wordToken sqlTokenKind = iota
+	quotedToken
+	stringToken
+	numberToken
+	placeholderToken
+	punctToken
+)
+
+// A sqlToken is a lexical token of parsed SQL.
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+	// space is whether the token was preceded by whitespace or a comment.
+	space bool
+	// n is the number of a placeholder.
+	n int
+}
+
+func (t sqlToken) is(s string) bool {
+	if t.kind == wordToken {
+		return strings.EqualFold(t.text, s)
+	}
+	return t.kind == punctToken && t.text == s
+}
+
+func isWordStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isWordPart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == '#'
+}
+
+const operatorChars = "+-*/<>=~!@#%^&|:?"
+
+// lexSQL splits SQL into tokens, recognising the placeholders of the dialect.
+func lexSQL(sql string, d Dialect) ([]sqlToken, error) {
+	var (
+		toks  []sqlToken
+		rs    = []rune(sql)
+		space bool
+		seq   int
+		max   int
+		named = map[string]int{}
+	)
+
+	emit := func(kind sqlTokenKind, text string, n int) {
+		toks = append(toks, sqlToken{kind: kind, text: text, space: space, n: n})
+		space = false
+	}
+
+	// placeholder numbers a placeholder by its position, or, by its name, with
+	// a negative number which is made to follow the positional ones once they
+	// are all known.
+	placeholder := func(name string) int {
+		n, err := strconv.Atoi(name)
+		switch {
+		case name == "":
+			seq++
+			n = seq
+		case err != nil:
+			if _, ok := named[name]; !ok {
+				named[name] = -(len(named) + 1)
+			}
+			return named[name]
+		}
+
+		if n > max {
+			max = n
+		}
+		return n
+	}
+
+	scanWhile := func(i int, f func(r rune) bool) int {
+		for i < len(rs) && f(rs[i]) {
+			i++
+		}
+		return i
+	}
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		next := rune(0)
+		if i+1 < len(rs) {
+			next = rs[i+1]
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			i++
+
+		case r == '-' && next == '-':
+			i = scanWhile(i, func(r rune) bool { return r != '\n' })
+			space = true
+
+		case r == '/' && next == '*':
+			end := strings.Index(string(rs[i+2:]), "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("qb: parse: unterminated comment")
+			}
+			i += 2 + len([]rune(string(rs[i+2:])[:end])) + 2
+			space = true
+
+		case r == '\'':
+			j, err := scanQuoted(rs, i, '\'', d == DialectMysql)
+			if err != nil {
+				return nil, err
+			}
+			emit(stringToken, string(rs[i:j]), 0)
+			i = j
+
+		case r == '"' || r == '`' && (d == DialectMysql || d == DialectSqlite):
+			j, err := scanQuoted(rs, i, r, false)
+			if err != nil {
+				return nil, err
+			}
+			emit(quotedToken, string(rs[i:j]), 0)
+			i = j
+
+		case r == '[' && d == DialectMssql:
+			j, err := scanQuoted(rs, i, ']', false)
+			if err != nil {
+				return nil, err
+			}
+			emit(quotedToken, string(rs[i:j]), 0)
+			i = j
+
+		case r == '$' && d == DialectPq && unicode.IsDigit(next):
+			j := scanWhile(i+1, unicode.IsDigit)
+			emit(placeholderToken, string(rs[i:j]), placeholder(string(rs[i+1:j])))
+			i = j
+
+		case r == '$' && d == DialectPq:
+			// A dollar-quoted string: $tag$ ... $tag$
+			j := scanWhile(i+1, isWordPart)
+			if j >= len(rs) || rs[j] != '$' {
+				return nil, fmt.Errorf("qb: parse: unexpected %q", r)
+			}
+			tag := string(rs[i : j+1])
+			end := strings.Index(string(rs[j+1:]), tag)
+			if end < 0 {
+				return nil, fmt.Errorf("qb: parse: unterminated string %s", tag)
+			}
+			j += 1 + len([]rune(string(rs[j+1:])[:end])) + len([]rune(tag))
+			emit(stringToken, string(rs[i:j]), 0)
+			i = j
+
+		case r == '?' && d != DialectPq && d != DialectGoracle && d != DialectMssql:
+			j := scanWhile(i+1, unicode.IsDigit)
+			emit(placeholderToken, string(rs[i:j]), placeholder(string(rs[i+1:j])))
+			i = j
+
+		case r == ':' && d == DialectGoracle && (isWordPart(next) && next != '$' && next != '#'):
+			j := scanWhile(i+1, isWordPart)
+			emit(placeholderToken, string(rs[i:j]), placeholder(string(rs[i+1:j])))
+			i = j
+
+		case r == '@' && d == DialectMssql && isWordStart(next):
+			j := scanWhile(i+1, isWordPart)
+			name := string(rs[i+1 : j])
+			if len(name) > 1 && (name[0] == 'p' || name[0] == 'P') {
+				if _, err := strconv.Atoi(name[1:]); err == nil {
+					name = name[1:]
+				}
+			}
+			emit(placeholderToken, string(rs[i:j]), placeholder(name))
+			i = j
+
+		case (r == 'E' || r == 'e') && next == '\'' && d == DialectPq:
+			// An escape string: E'...', in which \' is a quote.
+			j, err := scanQuoted(rs, i+1, '\'', true)
+			if err != nil {
+				return nil, err
+			}
+			emit(stringToken, string(rs[i:j]), 0)
+			i = j
+
+		case isWordStart(r) || r == '#' && d == DialectMssql || r == '@' && next == '@':
+			j := scanWhile(i+1, func(r rune) bool { return isWordPart(r) || r == '@' })
+			emit(wordToken, string(rs[i:j]), 0)
+			i = j
+
+		case unicode.IsDigit(r) || r == '.' && unicode.IsDigit(next):
+			j := scanWhile(i, func(r rune) bool { return unicode.IsDigit(r) || r == '.' })
+			if j < len(rs) && (rs[j] == 'e' || rs[j] == 'E') {
+				k := j + 1
+				if k < len(rs) && (rs[k] == '+' || rs[k] == '-') {
+					k++
+				}
+				if k < len(rs) && unicode.IsDigit(rs[k]) {
+					j = scanWhile(k, unicode.IsDigit)
+				}
+			}
+			emit(numberToken, string(rs[i:j]), 0)
+			i = j
+
+		case strings.ContainsRune("(),;.[]{}", r):
+			emit(punctToken, string(r), 0)
+			i++
+
+		case strings.ContainsRune(operatorChars, r):
+			j := scanWhile(i, func(r rune) bool {
+				return strings.ContainsRune(operatorChars, r)
+			})
+			emit(punctToken, string(rs[i:j]), 0)
+			i = j
+
+		default:
+			return nil, fmt.Errorf("qb: parse: unexpected %q", r)
+		}
+	}
+
+	for i := range toks {
+		if toks[i].kind == placeholderToken && toks[i].n < 0 {
+			toks[i].n = max - toks[i].n
+		}
+	}
+	return toks, nil
+}
+
+// scanQuoted returns the index after the quoted string starting at i. The
+// quote is escaped by doubling it, or by a backslash if backslash is true.
+func scanQuoted(rs []rune, i int, quote rune, backslash bool) (int, error) {
+	for j := i + 1; j < len(rs); j++ {
+		switch {
+		case backslash && rs[j] == '\\':
+			j++
+		case rs[j] == quote:
+			if j+1 < len(rs) && rs[j+1] == quote && quote != ']' {
+				j++
+				continue
+			}
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("qb: parse: unterminated %s", string(rs[i:i+1]))
+}
+
+// tokensText returns the SQL of tokens, keeping their original spacing.
+func tokensText(toks []sqlToken) string {
+	var b strings.Builder
+	for i, t := range toks {
+		if i > 0 && t.space {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+// splitTokens splits tokens at the given separator outside parentheses.
+func splitTokens(toks []sqlToken, sep string) [][]sqlToken {
+	var (
+		out   [][]sqlToken
+		depth int
+		start int
+	)
+	for i, t := range toks {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.is(sep):
+			out = append(out, toks[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, toks[start:])
+}
+
+// closingParen returns the index of the parenthesis closing the one at i.
+func closingParen(toks []sqlToken, i int) (int, error) {
+	depth := 0
+	for j := i; j < len(toks); j++ {
+		switch {
+		case toks[j].is("("):
+			depth++
+		case toks[j].is(")"):
+			depth--
+			if depth == 0 {
+				return j, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("qb: parse: unbalanced parentheses")
+}
+
+// hasWord reports whether one of the tokens outside parentheses is the given
+// keyword.
+func hasWord(toks []sqlToken, word string) bool {
+	depth := 0
+	for _, t := range toks {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.is(word):
+			return true
+		}
+	}
+	return false
+}
+
+// writeTokens writes tokens as SQL, placeholders as arguments, and subqueries
+// as nested queries.
+func writeTokens(w *sqlWriter, toks []sqlToken, d Dialect) error {
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			w.WriteSQL(tokensText(toks[start:end]))
+		}
+	}
+
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch {
+		case t.kind == placeholderToken:
+			flush(i)
+			w.WriteArg(Placeholder(t.n))
+			start = i + 1
+
+		case t.is("(") && i+1 < len(toks) && (toks[i+1].is("SELECT") || toks[i+1].is("WITH")):
+			j, err := closingParen(toks, i)
+			if err != nil {
+				return err
+			}
+
+			sq, err := parseQuery(toks[i+1:j], d)
+			if err != nil {
+				return err
+			}
+
+			flush(i)
+			w.WriteValue(sq)
+			start = j + 1
+			i = j
+		}
+	}
+
+	flush(len(toks))
+	return nil
+}
+
+// A queryParser parses one statement into a Query.
+type queryParser struct {
+	toks []sqlToken
+	i    int
+	q    Query
+}
+
+func parseQuery(toks []sqlToken, d Dialect) (Query, error) {
+	p := &queryParser{toks: toks, q: Query{Dialect: d}}
+	if err := p.parse(); err != nil {
+		return Query{}, err
+	}
+	return p.q, nil
+}
+
+func (p *queryParser) done() bool {
+	return p.i >= len(p.toks)
+}
+
+// at reports whether the tokens from i on are the given keywords.
+func (p *queryParser) at(i int, words ...string) bool {
+	if i+len(words) > len(p.toks) {
+		return false
+	}
+
+	for j, word := range words {
+		if !p.toks[i+j].is(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// accept skips the given keywords if they come next.
+func (p *queryParser) accept(words ...string) bool {
+	if !p.at(p.i, words...) {
+		return false
+	}
+
+	p.i += len(words)
+	return true
+}
+
+func (p *queryParser) unexpected() error {
+	if p.done() {
+		return fmt.Errorf("qb: parse: unexpected end of statement")
+	}
+	return fmt.Errorf("qb: parse: unexpected %q", p.toks[p.i].text)
+}
+
+// clauseStarts lists the keywords which start a clause of a statement.
+var clauseStarts = [][]string{
+	{"SELECT"}, {"FROM"}, {"WHERE"}, {"GROUP", "BY"}, {"HAVING"}, {"WINDOW"},
+	{"ORDER", "BY"}, {"LIMIT"}, {"OFFSET"}, {"FETCH"}, {"FOR", "UPDATE"},
+	{"FOR", "NO", "KEY"}, {"FOR", "SHARE"}, {"FOR", "KEY", "SHARE"},
+	{"LOCK", "IN"}, {"UNION"}, {"INTERSECT"}, {"EXCEPT"}, {"RETURNING"},
+	{"SET"}, {"USING"}, {"ON", "CONFLICT"}, {"ON", "DUPLICATE"},
+}
+
+// clause returns the tokens up to the next of the given clause keywords
+// outside parentheses.
+func (p *queryParser) clause(stops ...string) []sqlToken {
+	start, depth := p.i, 0
+	for ; !p.done(); p.i++ {
+		t := p.toks[p.i]
+		switch {
+		case t.is("("):
+			depth++
+			continue
+		case t.is(")"):
+			depth--
+			continue
+		case depth > 0 || t.kind != wordToken:
+			continue
+		case t.is("FROM") && p.i > 0 && p.toks[p.i-1].is("DISTINCT"):
+			// IS [NOT] DISTINCT FROM
+			continue
+		}
+
+		for _, words := range clauseStarts {
+			if !p.at(p.i, words...) {
+				continue
+			}
+
+			for _, stop := range stops {
+				if strings.EqualFold(stop, strings.Join(words, " ")) {
+					return p.toks[start:p.i]
+				}
+			}
+		}
+	}
+	return p.toks[start:]
+}
+
+var selectStops = []string{
+	"FROM", "WHERE", "GROUP BY", "HAVING", "WINDOW", "ORDER BY", "LIMIT",
+	"OFFSET", "FETCH", "FOR UPDATE", "FOR NO KEY", "FOR SHARE",
+	"FOR KEY SHARE", "LOCK IN", "UNION", "INTERSECT", "EXCEPT", "RETURNING",
+}
+
+func (p *queryParser) parse() error {
+	if p.accept("WITH") {
+		if err := p.parseWith(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case p.at(p.i, "SELECT"):
+		return p.parseSelect()
+	case p.accept("INSERT", "INTO"):
+		return p.parseInsert()
+	case p.accept("UPDATE"):
+		return p.parseUpdate()
+	case p.accept("DELETE", "FROM"):
+		return p.parseDelete()
+	default:
+		return p.unexpected()
+	}
+}
+
+//  WITH [RECURSIVE] name AS ( query )[, ...]
+func (p *queryParser) parseWith() error {
+	recursive := p.accept("RECURSIVE")
+
+	for {
+		start := p.i
+		for !p.done() && !p.toks[p.i].is("AS") {
+			p.i++
+		}
+
+		name := tokensText(p.toks[start:p.i])
+		if name == "" || !p.accept("AS") {
+			return p.unexpected()
+		}
+
+		if p.done() || !p.toks[p.i].is("(") {
+			return p.unexpected()
+		}
+
+		end, err := closingParen(p.toks, p.i)
+		if err != nil {
+			return err
+		}
+
+		sq, err := parseQuery(p.toks[p.i+1:end], p.q.Dialect)
+		if err != nil {
+			return err
+		}
+
+		if recursive {
+			name = "RECURSIVE " + name
+			recursive = false
+		}
+
+		p.q = p.q.With(name, sq)
+		p.i = end + 1
+
+		if !p.accept(",") {
+			return nil
+		}
+	}
+}
+
+func (p *queryParser) parseSelect() error {
+	for p.accept("SELECT") {
+		columns := p.clause(selectStops...)
+		if len(columns) == 0 {
+			return p.unexpected()
+		}
+
+		p.q.last = selectExpr
+		p.q.w.WriteSQL("SELECT")
+		if err := p.writeList(columns); err != nil {
+			return err
+		}
+
+		if err := p.parseClauses(); err != nil {
+			return err
+		}
+
+		combined := false
+		for _, op := range []string{"UNION", "INTERSECT", "EXCEPT"} {
+			if !p.accept(op) {
+				continue
+			}
+
+			if p.accept("ALL") {
+				op += " ALL"
+			}
+			p.q = p.q.appending(combiningQuery, op)
+			combined = true
+		}
+
+		if !combined {
+			break
+		}
+	}
+
+	if !p.done() {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// parseClauses parses the clauses which may follow the head of a statement.
+func (p *queryParser) parseClauses() error {
+	for !p.done() {
+		var err error
+		switch {
+		case p.accept("FROM"):
+			err = p.parseTables("FROM", fromExpr)
+		case p.accept("USING"):
+			err = p.parseTables("USING", usingExpr)
+		case p.accept("WHERE"):
+			err = p.parseCondition("WHERE", whereExpr)
+		case p.accept("HAVING"):
+			err = p.parseCondition("HAVING", havingExpr)
+		case p.accept("GROUP", "BY"):
+			err = p.parseExpr("GROUP BY", groupByExpr)
+		case p.accept("WINDOW"):
+			err = p.parseExpr("WINDOW", anyExpr)
+		case p.accept("ORDER", "BY"):
+			err = p.parseExpr("ORDER BY", orderByExpr)
+		case p.accept("RETURNING"):
+			err = p.parseExpr("RETURNING", returningExpr)
+		case p.accept("LIMIT"):
+			err = p.parseLimit()
+		case p.accept("OFFSET"):
+			err = p.parseOffset()
+		case p.accept("FETCH"):
+			err = p.parseFetch()
+		case p.at(p.i, "FOR"):
+			err = p.parseLocking()
+		case p.accept("LOCK", "IN", "SHARE", "MODE"):
+			p.q = p.q.ForShare()
+		default:
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeList writes a comma separated list of expressions.
+func (p *queryParser) writeList(toks []sqlToken) error {
+	for i, item := range splitTokens(toks, ",") {
+		if i > 0 {
+			p.q.w.WriteSQL(",")
+		}
+
+		if err := writeTokens(&p.q.w, item, p.q.Dialect); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *queryParser) parseExpr(keyword string, t expressionType) error {
+	toks := p.clause(selectStops...)
+	if len(toks) == 0 {
+		return p.unexpected()
+	}
+
+	p.q.last = t
+	p.q.ordered = p.q.ordered || t == orderByExpr
+	p.q.w.WriteSQL(keyword)
+	return writeTokens(&p.q.w, toks, p.q.Dialect)
+}
+
+// parseCondition parses the condition of a WHERE or HAVING clause.
+func (p *queryParser) parseCondition(keyword string, t expressionType) error {
+	pred, err := p.predicate(p.clause(selectStops...))
+	if err != nil {
+		return err
+	}
+
+	if t == havingExpr {
+		p.q = p.q.Having(pred)
+	} else {
+		p.q = p.q.Where(pred)
+	}
+	return nil
+}
+
+// predicate parses a condition. A condition with a top-level OR is
+// parenthesised, so that a predicate added to it with AND applies to the
+// whole condition.
+func (p *queryParser) predicate(toks []sqlToken) (Predicate, error) {
+	if len(toks) == 0 {
+		return Predicate{}, p.unexpected()
+	}
+
+	var pred Predicate
+	if err := writeTokens(&pred.w, toks, p.q.Dialect); err != nil {
+		return Predicate{}, err
+	}
+
+	pred.count = 1
+	if hasWord(toks, "OR") {
+		pred.or = true
+		pred = AndP(pred)
+	}
+	return pred, nil
+}
+
+var joinWords = []string{"JOIN", "INNER", "LEFT", "RIGHT", "FULL", "CROSS", "NATURAL"}
+
+// parseTables parses the table expressions of a FROM or USING clause.
+//  FROM table0[, table1[, ...]] [[type] JOIN table ON predicate | USING (columns)]
+func (p *queryParser) parseTables(keyword string, t expressionType) error {
+	toks := p.clause(selectStops...)
+	p.q.last = t
+	p.q.w.WriteSQL(keyword)
+
+	isJoin := func(t sqlToken) bool {
+		for _, word := range joinWords {
+			if t.is(word) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// next returns the index of the first token from i on which is outside
+	// parentheses and satisfies stop.
+	next := func(i int, stop func(t sqlToken) bool) (int, error) {
+		for ; i < len(toks); i++ {
+			if toks[i].is("(") {
+				j, err := closingParen(toks, i)
+				if err != nil {
+					return 0, err
+				}
+				i = j
+				continue
+			}
+
+			if stop(toks[i]) {
+				break
+			}
+		}
+		return i, nil
+	}
+
+	endOfTable := func(t sqlToken) bool {
+		return t.is(",") || t.is("ON") || t.is("USING") || isJoin(t)
+	}
+
+	endOfJoin := func(t sqlToken) bool {
+		return t.is(",") || isJoin(t)
+	}
+
+	for i := 0; i < len(toks); {
+		switch {
+		case toks[i].is(","):
+			p.q.w.WriteSQL(",")
+			i++
+
+		case isJoin(toks[i]):
+			j := i
+			for j < len(toks) && !toks[j].is("JOIN") {
+				j++
+			}
+			if j == len(toks) {
+				return fmt.Errorf("qb: parse: expected JOIN after %q", tokensText(toks[i:]))
+			}
+
+			p.q.last = joinExpr
+			p.q.w.WriteSQL(strings.ToUpper(tokensText(toks[i : j+1])))
+			i = j + 1
+			continue
+
+		case toks[i].is("ON"), toks[i].is("USING"):
+			j, err := next(i+1, endOfJoin)
+			if err != nil {
+				return err
+			}
+
+			if toks[i].is("USING") {
+				p.q.w.WriteSQL("USING")
+				if err := writeTokens(&p.q.w, toks[i+1:j], p.q.Dialect); err != nil {
+					return err
+				}
+				i = j
+				continue
+			}
+
+			pred, err := p.predicate(toks[i+1 : j])
+			if err != nil {
+				return err
+			}
+
+			p.q.w.WriteSQL("ON")
+			p.q.w.WriteFragment(condition{pred})
+			i = j
+			continue
+		}
+
+		if i == len(toks) {
+			break
+		}
+
+		j, err := next(i, endOfTable)
+		if err != nil {
+			return err
+		}
+
+		if err := p.writeTable(toks[i:j]); err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+// writeTable writes a table expression. Plain tables are recorded as tables
+// of the query.
+func (p *queryParser) writeTable(toks []sqlToken) error {
+	if len(toks) == 0 {
+		return p.unexpected()
+	}
+
+	for _, t := range toks {
+		if t.kind == placeholderToken || t.is("(") {
+			return writeTokens(&p.q.w, toks, p.q.Dialect)
+		}
+	}
+
+	p.q = p.q.writeTable(tokensText(toks))
+	return nil
+}
+
+// parseTerm parses the value of a LIMIT, OFFSET or FETCH clause.
+func (p *queryParser) parseTerm() (paginationTerm, error) {
+	if p.done() {
+		return paginationTerm{}, p.unexpected()
+	}
+
+	t := p.toks[p.i]
+	p.i++
+	switch t.kind {
+	case numberToken:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return paginationTerm{}, fmt.Errorf("qb: parse: invalid row count %q", t.text)
+		}
+		return paginationTerm{set: true, n: n}, nil
+	case placeholderToken:
+		return paginationTerm{set: true, arg: true, value: Placeholder(t.n)}, nil
+	}
+
+	if t.is("ALL") {
+		return paginationTerm{set: true, all: true}, nil
+	}
+
+	p.i--
+	return paginationTerm{}, p.unexpected()
+}
+
+//  LIMIT count
+//  LIMIT offset, count (mysql)
+func (p *queryParser) parseLimit() error {
+	term, err := p.parseTerm()
+	if err != nil {
+		return err
+	}
+
+	if p.accept(",") {
+		p.q = p.q.paginating(offsetExpr, term)
+		if term, err = p.parseTerm(); err != nil {
+			return err
+		}
+	}
+
+	p.q = p.q.paginating(limitExpr, term)
+	return nil
+}
+
+//  OFFSET offset [ROW | ROWS]
+func (p *queryParser) parseOffset() error {
+	term, err := p.parseTerm()
+	if err != nil {
+		return err
+	}
+
+	_ = p.accept("ROWS") || p.accept("ROW")
+	p.q = p.q.paginating(offsetExpr, term)
+	return nil
+}
+
+//  FETCH {FIRST | NEXT} count {ROW | ROWS} ONLY
+func (p *queryParser) parseFetch() error {
+	if !p.accept("FIRST") && !p.accept("NEXT") {
+		return p.unexpected()
+	}
+
+	term, err := p.parseTerm()
+	if err != nil {
+		return err
+	}
+
+	if !p.accept("ROWS", "ONLY") && !p.accept("ROW", "ONLY") {
+		return p.unexpected()
+	}
+
+	p.q = p.q.paginating(limitExpr, term)
+	return nil
+}
+
+//  FOR strength [OF table0[, ...]] [NOWAIT | SKIP LOCKED]
+func (p *queryParser) parseLocking() error {
+	switch {
+	case p.accept("FOR", "UPDATE"):
+		p.q = p.q.ForUpdate()
+	case p.accept("FOR", "NO", "KEY", "UPDATE"):
+		p.q = p.q.ForNoKeyUpdate()
+	case p.accept("FOR", "SHARE"):
+		p.q = p.q.ForShare()
+	case p.accept("FOR", "KEY", "SHARE"):
+		p.q = p.q.ForKeyShare()
+	default:
+		return p.unexpected()
+	}
+
+	if p.accept("OF") {
+		var tables []string
+		for {
+			if p.done() {
+				return p.unexpected()
+			}
+
+			tables = append(tables, p.toks[p.i].text)
+			p.i++
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.q = p.q.Of(tables...)
+	}
+
+	switch {
+	case p.accept("NOWAIT"):
+		p.q = p.q.NoWait()
+	case p.accept("SKIP", "LOCKED"):
+		p.q = p.q.SkipLocked()
+	}
+	return nil
+}
+
+//  INSERT INTO table [(columns)] {VALUES (...)[, ...] | query | DEFAULT VALUES}
+//  	[ON ...] [RETURNING ...]
+func (p *queryParser) parseInsert() error {
+	start := p.i
+	for !p.done() && !p.toks[p.i].is("(") && !p.at(p.i, "VALUES") &&
+		!p.at(p.i, "SELECT") && !p.at(p.i, "WITH") && !p.at(p.i, "DEFAULT") {
+		p.i++
+	}
+
+	table := tokensText(p.toks[start:p.i])
+	if table == "" {
+		return p.unexpected()
+	}
+
+	var columns []string
+	if !p.done() && p.toks[p.i].is("(") {
+		end, err := closingParen(p.toks, p.i)
+		if err != nil {
+			return err
+		}
+
+		for _, column := range splitTokens(p.toks[p.i+1:end], ",") {
+			columns = append(columns, tokensText(column))
+		}
+		p.i = end + 1
+	}
+
+	p.q = p.q.InsertInto(table, columns...)
+
+	switch {
+	case p.accept("DEFAULT", "VALUES"):
+		p.q = p.q.DefaultValues()
+
+	case p.accept("VALUES"):
+		toks := p.clause("ON CONFLICT", "ON DUPLICATE", "RETURNING")
+		p.q.last = valuesExpr
+		p.q.w.WriteSQL("VALUES")
+		for i, tuple := range splitTokens(toks, ",") {
+			if i > 0 {
+				p.q.w.WriteSQL(",")
+			}
+
+			if len(tuple) < 2 || !tuple[0].is("(") || !tuple[len(tuple)-1].is(")") {
+				return fmt.Errorf("qb: parse: invalid VALUES tuple %q", tokensText(tuple))
+			}
+
+			p.q.w.WriteSQL("(")
+			if err := p.writeList(tuple[1 : len(tuple)-1]); err != nil {
+				return err
+			}
+			p.q.w.WriteSQL(")")
+		}
+
+	case p.at(p.i, "SELECT"), p.at(p.i, "WITH"):
+		toks := p.clause("ON CONFLICT", "ON DUPLICATE", "RETURNING")
+		sq, err := parseQuery(toks, p.q.Dialect)
+		if err != nil {
+			return err
+		}
+
+		p.q = p.q.appendQuery(sq)
+
+	default:
+		return p.unexpected()
+	}
+
+	if p.at(p.i, "ON", "CONFLICT") || p.at(p.i, "ON", "DUPLICATE") {
+		toks := p.clause("RETURNING")
+		p.q.last = anyExpr
+		if err := writeTokens(&p.q.w, toks, p.q.Dialect); err != nil {
+			return err
+		}
+	}
+
+	return p.finish()
+}
+
+//  UPDATE table SET assignment0[, ...] [FROM ...] [WHERE ...] [RETURNING ...]
+func (p *queryParser) parseUpdate() error {
+	table := p.clause("SET")
+	if len(table) == 0 || !p.accept("SET") {
+		return p.unexpected()
+	}
+	p.q = p.q.Update(tokensText(table))
+
+	for i, assignment := range splitTokens(p.clause(selectStops...), ",") {
+		prefix := "SET"
+		if i > 0 {
+			prefix = ","
+		}
+
+		p.q.last = setExpr
+		p.q.w.WriteSQL(prefix)
+		if err := writeTokens(&p.q.w, assignment, p.q.Dialect); err != nil {
+			return err
+		}
+	}
+
+	return p.finish()
+}
+
+//  DELETE FROM table [USING ...] [WHERE ...] [RETURNING ...]
+func (p *queryParser) parseDelete() error {
+	table := p.clause("USING", "WHERE", "ORDER BY", "LIMIT", "RETURNING")
+	if len(table) == 0 {
+		return p.unexpected()
+	}
+
+	p.q = p.q.DeleteFrom(tokensText(table))
+	return p.finish()
+}
+
+// appendQuery appends the SQL and the tables of a query.
+func (q Query) appendQuery(sq Query) Query {
+	tables1 := make([]tableRef, 0, len(q.tables)+len(sq.tables))
+	tables1 = append(tables1, q.tables...)
+	for _, ref := range sq.tables {
+		ref.at += q.w.Len()
+		tables1 = append(tables1, ref)
+	}
+
+	q.tables = tables1
+	q.w.Append(&sq.w)
+	q.last = sq.last
+	return q
+}
+
+// finish parses the remaining clauses of a statement.
+func (p *queryParser) finish() error {
+	if err := p.parseClauses(); err != nil {
+		return err
+	}
+
+	if !p.done() {
+		return p.unexpected()
+	}
+	return nil
+}
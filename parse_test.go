@@ -0,0 +1,185 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect qb.Dialect
+		expr    string
+		args    []interface{}
+		extend  func(q qb.Query) qb.Query
+	}{
+		{
+			name:    "select with where",
+			sql:     `SELECT id, count(*) AS n FROM users WHERE org_id = $1 AND name <> $2`,
+			dialect: qb.DialectPq,
+			expr:    `SELECT id , count(*) AS n FROM users WHERE org_id = $1 AND name <> $2 AND age > $3 ORDER BY id LIMIT 10`,
+			args:    []interface{}{qb.Placeholder(1), qb.Placeholder(2), 18},
+			extend: func(q qb.Query) qb.Query {
+				return q.Where(qb.And("age > ?", 18)).OrderBy("id").Limit(10)
+			},
+		},
+		{
+			name:    "reused placeholder",
+			sql:     `SELECT * FROM t WHERE a = $1 OR b = $1`,
+			dialect: qb.DialectPq,
+			expr:    `SELECT * FROM t WHERE ( a = $1 OR b = $2 ) AND c = $3`,
+			args:    []interface{}{qb.Placeholder(1), qb.Placeholder(1), 3},
+			extend: func(q qb.Query) qb.Query {
+				return q.Where(qb.And("c = ?", 3))
+			},
+		},
+		{
+			name:    "joins and subquery",
+			sql:     `select u.* from users u left join orgs o on o.id = u.org_id where u.id in (select user_id from admins where level > ?) -- admins only`,
+			dialect: qb.DialectDefault,
+			expr:    `SELECT u.* FROM users u LEFT JOIN orgs o ON o.id = u.org_id WHERE u.id in ( SELECT user_id FROM admins WHERE level > ? )`,
+			args:    []interface{}{qb.Placeholder(1)},
+		},
+		{
+			name:    "with and union",
+			sql:     `WITH recent AS (SELECT * FROM orders WHERE created_at > ?) SELECT id FROM recent UNION ALL SELECT id FROM archived WHERE note = 'a ? b'`,
+			dialect: qb.DialectDefault,
+			expr:    `WITH recent AS ( SELECT * FROM orders WHERE created_at > ? ) SELECT id FROM recent UNION ALL SELECT id FROM archived WHERE note = 'a ? b'`,
+			args:    []interface{}{qb.Placeholder(1)},
+		},
+		{
+			name:    "pagination and locking",
+			sql:     `SELECT * FROM jobs ORDER BY id OFFSET @p1 ROWS FETCH NEXT 5 ROWS ONLY`,
+			dialect: qb.DialectMssql,
+			expr:    `SELECT * FROM jobs WITH (UPDLOCK) ORDER BY id OFFSET @p1 ROWS FETCH NEXT 5 ROWS ONLY`,
+			args:    []interface{}{qb.Placeholder(1)},
+			extend: func(q qb.Query) qb.Query {
+				return q.ForUpdate()
+			},
+		},
+		{
+			name:    "mysql limit",
+			sql:     "SELECT * FROM `jobs` LIMIT 10, 5 FOR UPDATE SKIP LOCKED",
+			dialect: qb.DialectMysql,
+			expr:    "SELECT * FROM `jobs` LIMIT 5 OFFSET 10 FOR UPDATE SKIP LOCKED",
+			args:    []interface{}{},
+		},
+		{
+			name:    "insert values",
+			sql:     `INSERT INTO users (id, name) VALUES (:1, :2)`,
+			dialect: qb.DialectGoracle,
+			expr:    `INSERT INTO users ( id , name ) VALUES ( :1 , :2 ) , ( :3 , :4 ) RETURNING id`,
+			args:    []interface{}{1, "a", 2, "b"},
+			extend: func(q qb.Query) qb.Query {
+				return q.Values(2, "b").Returning("id").WithArgs(1, "a")
+			},
+		},
+		{
+			name:    "insert select on conflict",
+			sql:     `INSERT INTO t (a) SELECT a FROM s JOIN r ON r.id = s.id ON CONFLICT (a) DO NOTHING`,
+			dialect: qb.DialectPq,
+			expr:    `INSERT INTO t ( a ) SELECT a FROM s JOIN r ON r.id = s.id ON CONFLICT (a) DO NOTHING`,
+			args:    []interface{}{},
+		},
+		{
+			name:    "update",
+			sql:     `UPDATE users SET name = ?, updated_at = now() WHERE id = ?`,
+			dialect: qb.DialectSqlite,
+			expr:    `UPDATE users SET name = ? , updated_at = now() WHERE id = ? AND deleted_at IS NULL`,
+			args:    []interface{}{"a", 1},
+			extend: func(q qb.Query) qb.Query {
+				return q.Where(qb.And("deleted_at IS NULL")).WithArgs("a", 1)
+			},
+		},
+		{
+			name:    "delete using",
+			sql:     `DELETE FROM orders USING users WHERE orders.user_id = users.id AND users.name = $1`,
+			dialect: qb.DialectPq,
+			expr:    `DELETE FROM orders USING users WHERE orders.user_id = users.id AND users.name = $1 RETURNING orders.id`,
+			args:    []interface{}{"a"},
+			extend: func(q qb.Query) qb.Query {
+				return q.Returning("orders.id").WithArgs("a")
+			},
+		},
+		{
+			name:    "named and positional placeholders",
+			sql:     `SELECT * FROM t WHERE a = @x AND b = @p2 AND c = @p1 AND d = @x`,
+			dialect: qb.DialectMssql,
+			expr:    `SELECT * FROM t WHERE a = @p1 AND b = @p2 AND c = @p3 AND d = @p4`,
+			args:    []interface{}{qb.Placeholder(3), qb.Placeholder(2), qb.Placeholder(1), qb.Placeholder(3)},
+		},
+		{
+			name:    "goracle named and positional placeholders",
+			sql:     `SELECT * FROM t WHERE a = :name AND b = :1`,
+			dialect: qb.DialectGoracle,
+			expr:    `SELECT * FROM t WHERE a = :1 AND b = :2`,
+			args:    []interface{}{"a", 1},
+			extend: func(q qb.Query) qb.Query {
+				return q.WithArgs(1, "a")
+			},
+		},
+		{
+			name:    "escape string",
+			sql:     `SELECT * FROM t WHERE a = E'it\'s $1' AND b = $1`,
+			dialect: qb.DialectPq,
+			expr:    `SELECT * FROM t WHERE a = E'it\'s $1' AND b = $1`,
+			args:    []interface{}{qb.Placeholder(1)},
+		},
+		{
+			name:    "multiple statements",
+			sql:     `DELETE FROM a; DELETE FROM b WHERE id = ?;`,
+			dialect: qb.DialectDefault,
+			expr:    `DELETE FROM a ; DELETE FROM b WHERE id = ? ;`,
+			args:    []interface{}{qb.Placeholder(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := qb.Parse(tt.sql, tt.dialect)
+			require.NoError(t, err)
+
+			if tt.extend != nil {
+				q = tt.extend(q)
+			}
+
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		sql string
+		err string
+	}{
+		{`CREATE TABLE t (a int)`, `qb: parse: unexpected "CREATE"`},
+		{`SELECT * FROM t WHERE`, `qb: parse: unexpected end of statement`},
+		{`SELECT * FROM t WHERE a IN (1, 2`, `qb: parse: unbalanced parentheses`},
+		{`SELECT 'a`, `qb: parse: unterminated '`},
+		{`SELECT * FROM t LIMIT n`, `qb: parse: unexpected "n"`},
+		{`;`, `qb: parse: empty statement`},
+	}
+
+	for _, tt := range tests {
+		_, err := qb.Parse(tt.sql, qb.DialectDefault)
+		require.EqualError(t, err, tt.err, tt.sql)
+	}
+}
+
+func TestQuery_WithArgs(t *testing.T) {
+	q, err := qb.Parse(`SELECT * FROM t WHERE a = ? LIMIT ?`, qb.DialectPq)
+	require.Error(t, err)
+
+	q, err = qb.Parse(`SELECT * FROM t WHERE a = $2 LIMIT $1`, qb.DialectPq)
+	require.NoError(t, err)
+	require.Equal(t, `SELECT * FROM t WHERE a = $1 LIMIT $2`, q.SQL())
+	bound := q.WithArgs(10, "a")
+	require.Equal(t, []interface{}{"a", 10}, bound.Args())
+	require.Panics(t, func() { q.WithArgs(10) })
+}
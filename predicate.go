@@ -3,6 +3,7 @@ package qb
 type Predicate struct {
 	w     sqlWriter
 	count int
+	or    bool
 }
 
 func (p Predicate) String() string {
@@ -54,6 +55,7 @@ func (my Predicate) AndP(predicate Predicate) Predicate {
 func (my Predicate) Or(expr string, args ...interface{}) Predicate {
 	if my.count > 0 {
 		my.w.WriteSQL("OR")
+		my.or = true
 	}
 
 	my.count += 1
@@ -68,6 +70,7 @@ func (my Predicate) OrP(predicate Predicate) Predicate {
 
 	if my.count > 0 {
 		my.w.WriteSQL("OR")
+		my.or = true
 	}
 
 	my.count += 1
@@ -77,6 +80,25 @@ func (my Predicate) OrP(predicate Predicate) Predicate {
 	return my
 }
 
+// and appends p with AND, parenthesising it if it contains OR.
+func (my Predicate) and(p Predicate) Predicate {
+	if p.or {
+		return my.AndP(p)
+	}
+
+	if p.IsEmpty() {
+		return my
+	}
+
+	if my.count > 0 {
+		my.w.WriteSQL("AND")
+	}
+
+	my.count += p.count
+	my.w.Append(&p.w)
+	return my
+}
+
 func (my Predicate) Map(f func(p Predicate) Predicate) Predicate {
 	return f(my)
 }
@@ -11,8 +11,29 @@ const (
 	DialectPq
 	DialectGoracle
 	DialectMssql
+	DialectMysql
+	DialectSqlite
 )
 
+func dialectName(d Dialect) string {
+	switch d {
+	case DialectDefault:
+		return "default"
+	case DialectPq:
+		return "pq"
+	case DialectGoracle:
+		return "goracle"
+	case DialectMssql:
+		return "mssql"
+	case DialectMysql:
+		return "mysql"
+	case DialectSqlite:
+		return "sqlite"
+	default:
+		return fmt.Sprintf("unrecognised (%d)", d)
+	}
+}
+
 func Lit(s string, args ...interface{}) interface{} {
 	return literal(fmt.Sprintf(s, args...))
 }
@@ -33,7 +54,15 @@ func WithDialectMssql() Query {
 	return DialectOption(DialectMssql)
 }
 
-var NULL = Lit(`NULL`)
+func WithDialectMysql() Query {
+	return DialectOption(DialectMysql)
+}
+
+func WithDialectSqlite() Query {
+	return DialectOption(DialectSqlite)
+}
+
+var NULL interface{} = Raw(`NULL`)
 
 type Values map[string]interface{}
 
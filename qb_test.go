@@ -1,6 +1,7 @@
 package qb_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -331,6 +332,227 @@ func TestQuery(t *testing.T) {
 						And(`y = ?`, 2))
 			},
 		},
+		{
+			name: "limit and offset with arguments",
+			expr: `SELECT * FROM my_table ORDER BY a LIMIT $1 OFFSET $2`,
+			args: []interface{}{10, 5},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectPq).
+					Select("*").
+					From("my_table").
+					OrderBy("a").
+					OffsetArg(5).
+					LimitArg(10)
+			},
+		},
+		{
+			name: "limit and offset with mssql dialect",
+			expr: `SELECT * FROM my_table WHERE b = @p1 ORDER BY a OFFSET @p2 ROWS FETCH NEXT @p3 ROWS ONLY`,
+			args: []interface{}{1, 5, 10},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectMssql).
+					Select("*").
+					From("my_table").
+					Where(qb.And("b = ?", 1)).
+					OrderBy("a").
+					LimitArg(10).
+					OffsetArg(5)
+			},
+		},
+		{
+			name: "limit with mssql dialect",
+			expr: `SELECT * FROM my_table ORDER BY a OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectMssql).
+					Select("*").
+					From("my_table").
+					OrderBy("a").
+					Limit(10)
+			},
+		},
+		{
+			name: "limit with goracle dialect",
+			expr: `SELECT * FROM my_table FETCH NEXT 10 ROWS ONLY`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectGoracle).
+					Select("*").
+					From("my_table").
+					Limit(10)
+			},
+		},
+		{
+			name: "limit all and offset with goracle dialect",
+			expr: `SELECT * FROM my_table OFFSET 5 ROWS`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectGoracle).
+					Select("*").
+					From("my_table").
+					LimitAll().
+					Offset(5)
+			},
+		},
+		{
+			name: "offset with sqlite dialect",
+			expr: `SELECT * FROM my_table LIMIT -1 OFFSET 5`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectSqlite).
+					Select("*").
+					From("my_table").
+					Offset(5).
+					ForUpdate()
+			},
+		},
+		{
+			name: "limit in subquery with pq dialect",
+			expr: `SELECT * FROM ( SELECT * FROM t2 LIMIT $1 ) WHERE x = $2`,
+			args: []interface{}{10, 1},
+			query: func() qb.Query {
+				return qb.
+					Select("*").
+					FromSubquery(qb.Select("*").From("t2").LimitArg(10)).
+					Where(qb.And("x = ?", 1)).
+					DialectOption(qb.DialectPq)
+			},
+		},
+		{
+			name: "for update skip locked",
+			expr: `SELECT * FROM jobs WHERE state = $1 LIMIT 1 FOR UPDATE SKIP LOCKED`,
+			args: []interface{}{"new"},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectPq).
+					Select("*").
+					From("jobs").
+					Where(qb.And("state = ?", "new")).
+					Limit(1).
+					ForUpdate().
+					SkipLocked()
+			},
+		},
+		{
+			name: "for no key update of tables nowait",
+			expr: `SELECT * FROM t1 JOIN t2 USING ( a ) FOR NO KEY UPDATE OF t1, t2 NOWAIT FOR KEY SHARE`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.
+					Select("*").
+					From("t1").
+					JoinUsing("t2", "a").
+					ForNoKeyUpdate().
+					Of("t1", "t2").
+					NoWait().
+					ForKeyShare()
+			},
+		},
+		{
+			name: "for no key update with mysql dialect",
+			expr: `SELECT * FROM t1 FOR UPDATE SKIP LOCKED`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectMysql).
+					Select("*").
+					From("t1").
+					ForNoKeyUpdate().
+					SkipLocked()
+			},
+		},
+		{
+			name: "for update skip locked with mssql dialect",
+			expr: `SELECT * FROM jobs AS "j" WITH (UPDLOCK, READPAST) JOIN t2 ON j.id = t2.id WHERE state = @p1`,
+			args: []interface{}{"new"},
+			query: func() qb.Query {
+				return qb.
+					DialectOption(qb.DialectMssql).
+					Select("*").
+					FromAs("jobs", "j").
+					JoinOn("t2", qb.And("j.id = t2.id")).
+					Where(qb.And("state = ?", "new")).
+					ForUpdate().
+					Of("j").
+					SkipLocked()
+			},
+		},
+		{
+			name: "transaction",
+			expr: `BEGIN ISOLATION LEVEL SERIALIZABLE READ ONLY DEFERRABLE ; SELECT * FROM t1 WHERE a = $1 ; COMMIT ;`,
+			args: []interface{}{1},
+			query: func() qb.Query {
+				return qb.Multiple(
+					qb.Begin().IsolationLevel(qb.Serializable).ReadOnly().Deferrable(),
+					qb.Select("*").From("t1").Where(qb.And("a = ?", 1)),
+					qb.Commit()).
+					DialectOption(qb.DialectPq)
+			},
+		},
+		{
+			name: "transaction with savepoints",
+			expr: `BEGIN ; SAVEPOINT s1 ; ROLLBACK TO SAVEPOINT s1 ; RELEASE SAVEPOINT s1 ; ROLLBACK ;`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Multiple(
+					qb.Begin(),
+					qb.Savepoint("s1"),
+					qb.RollbackTo("s1"),
+					qb.ReleaseSavepoint("s1"),
+					qb.Rollback())
+			},
+		},
+		{
+			name: "transaction with mysql dialect",
+			expr: `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ ; START TRANSACTION READ ONLY ; COMMIT ;`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Multiple(
+					qb.Begin().IsolationLevel(qb.RepeatableRead).ReadOnly(),
+					qb.Commit()).
+					DialectOption(qb.DialectMysql)
+			},
+		},
+		{
+			name: "transaction with mssql dialect",
+			expr: `SET TRANSACTION ISOLATION LEVEL SERIALIZABLE ; BEGIN TRANSACTION ; SAVE TRANSACTION s1 ; ROLLBACK TRANSACTION s1 ; COMMIT TRANSACTION ;`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Multiple(
+					qb.Begin().IsolationLevel(qb.Serializable),
+					qb.Savepoint("s1"),
+					qb.RollbackTo("s1"),
+					qb.Commit()).
+					DialectOption(qb.DialectMssql)
+			},
+		},
+		{
+			name: "typed columns",
+			expr: `SELECT id , email FROM users AS "u" WHERE u.email LIKE ? AND org_id IN ( ? , ? ) AND deleted_at IS NULL ORDER BY id DESC`,
+			args: []interface{}{"%@example.com", 1, 2},
+			query: func() qb.Query {
+				const (
+					id        qb.Col = "id"
+					email     qb.Col = "email"
+					orgID     qb.Col = "org_id"
+					deletedAt qb.Col = "deleted_at"
+				)
+
+				return qb.
+					Select(qb.Cols(id, email)...).
+					FromAs("users", "u").
+					Where(email.Of("u").Like("%@example.com")).
+					Where(orgID.In(1, 2)).
+					Where(deletedAt.IsNull()).
+					OrderBy(id.Desc())
+			},
+		},
 		//{
 		//	name: "simple insert with values",
 		//	expr: `INSERT INTO my_table ( a , b ) VALUES ( ? , ? )`,
@@ -352,3 +574,180 @@ func TestQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestQuery_Limit(t *testing.T) {
+	t.Run("mssql without ORDER BY", func(t *testing.T) {
+		q := qb.DialectOption(qb.DialectMssql).Select("*").From("t1").Limit(10)
+		require.Equal(t, `SELECT TOP (10) * FROM t1`, q.SQL())
+
+		q = qb.DialectOption(qb.DialectMssql).Select("DISTINCT a").From("t1").Where(qb.Pred("b = ?", 1)).LimitArg(10)
+		require.Equal(t, `SELECT DISTINCT TOP ( @p1 ) a FROM t1 WHERE b = @p2`, q.SQL())
+		require.Equal(t, []interface{}{10, 1}, q.Args())
+
+		q = qb.DialectOption(qb.DialectMssql).Select("*").FromSubquery(qb.Select("a").From("t1").Limit(5)).Append("AS t")
+		require.Equal(t, `SELECT * FROM ( SELECT TOP (5) a FROM t1 ) AS t`, q.SQL())
+
+		q = qb.DialectOption(qb.DialectMssql).Select("*").From("t1").Limit(10).Offset(5)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: OFFSET ... FETCH requires an ORDER BY clause in the mssql dialect")
+
+		q = qb.DialectOption(qb.DialectMssql).Select("a").From("t1").Union().Select("a").From("t2").Limit(10)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: OFFSET ... FETCH requires an ORDER BY clause in the mssql dialect")
+
+		q = qb.DialectOption(qb.DialectMssql).Select("a").SelectColumn("row_number() OVER (ORDER BY id)").From("t1").Offset(5).Limit(5)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: OFFSET ... FETCH requires an ORDER BY clause in the mssql dialect")
+
+		q = qb.DialectOption(qb.DialectMssql).Select("a").From("t1").OrderBy("a").Union().Select("a").From("t2").Limit(10)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: OFFSET ... FETCH requires an ORDER BY clause in the mssql dialect")
+	})
+
+	t.Run("mssql with ORDER BY before the last clause", func(t *testing.T) {
+		q := qb.DialectOption(qb.DialectMssql).Select("*").From("t1").OrderBy("a").Map(func(q qb.Query) qb.Query {
+			return q.Append("COLLATE Latin1_General_CI_AS")
+		}).Limit(10)
+		require.Equal(t, `SELECT * FROM t1 ORDER BY a COLLATE Latin1_General_CI_AS OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`, q.SQL())
+
+		q = qb.DialectOption(qb.DialectMssql).Select("*").From("t1").Append("ORDER BY a").Offset(5)
+		require.Equal(t, `SELECT * FROM t1 ORDER BY a OFFSET 5 ROWS`, q.SQL())
+
+		q, err := qb.Parse(`SELECT * FROM t1 ORDER BY a`, qb.DialectMssql)
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM t1 ORDER BY a OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`, q.Limit(10).SQL())
+	})
+}
+
+func TestQuery_ForUpdate(t *testing.T) {
+	t.Run("modifier without locking clause", func(t *testing.T) {
+		require.Panics(t, func() { qb.Select("*").From("t1").SkipLocked() })
+	})
+
+	t.Run("goracle", func(t *testing.T) {
+		q := qb.DialectOption(qb.DialectGoracle).Select("*").From("t1").ForUpdate().SkipLocked()
+		require.Equal(t, "SELECT * FROM t1 FOR UPDATE SKIP LOCKED", q.SQL())
+
+		for _, q := range []qb.Query{
+			qb.DialectOption(qb.DialectGoracle).Select("*").From("t1").ForShare(),
+			qb.DialectOption(qb.DialectGoracle).Select("*").From("t1").ForKeyShare(),
+			qb.DialectOption(qb.DialectGoracle).Select("*").From("t1").ForNoKeyUpdate(),
+		} {
+			require.Error(t, recoverError(func() { q.SQL() }))
+		}
+		q = qb.DialectOption(qb.DialectGoracle).Select("*").From("t1").ForShare()
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: FOR SHARE is not supported in the goracle dialect")
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		base := qb.DialectOption(qb.DialectMssql).Select("*").From("t1")
+		q1 := base.ForUpdate()
+		require.Equal(t, "SELECT * FROM t1 WITH (UPDLOCK)", q1.SQL())
+		require.Equal(t, "SELECT * FROM t1", base.SQL())
+	})
+}
+
+func TestQuery_Begin(t *testing.T) {
+	t.Run("modifier without Begin", func(t *testing.T) {
+		require.Panics(t, func() { qb.Commit().ReadOnly() })
+	})
+
+	t.Run("release savepoint with mssql dialect", func(t *testing.T) {
+		q := qb.ReleaseSavepoint("s1").DialectOption(qb.DialectMssql)
+		require.Panics(t, func() { q.SQL() })
+	})
+
+	t.Run("goracle", func(t *testing.T) {
+		q := qb.Begin().IsolationLevel(qb.Serializable).DialectOption(qb.DialectGoracle)
+		require.Equal(t, `SET TRANSACTION ISOLATION LEVEL SERIALIZABLE`, q.SQL())
+
+		q = qb.Begin().ReadOnly().DialectOption(qb.DialectGoracle)
+		require.Equal(t, `SET TRANSACTION READ ONLY`, q.SQL())
+
+		q = qb.Begin().IsolationLevel(qb.RepeatableRead).DialectOption(qb.DialectGoracle)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: isolation level REPEATABLE READ is not supported in the goracle dialect")
+
+		q = qb.Begin().IsolationLevel(qb.ReadUncommitted).DialectOption(qb.DialectGoracle)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: isolation level READ UNCOMMITTED is not supported in the goracle dialect")
+
+		q = qb.Begin().DialectOption(qb.DialectGoracle)
+		require.EqualError(t, recoverError(func() { q.SQL() }), "qb: BEGIN without an isolation level or READ ONLY is not supported in the goracle dialect, where transactions begin implicitly")
+	})
+
+	t.Run("statements", func(t *testing.T) {
+		q := qb.Multiple(
+			qb.Begin().IsolationLevel(qb.RepeatableRead).ReadOnly(),
+			qb.Update("t1").Set("a = ?", 1),
+			qb.Commit()).
+			DialectOption(qb.DialectMysql)
+		require.Equal(t, []qb.BatchQuery{
+			{SQL: `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ`, Args: []interface{}{}},
+			{SQL: `START TRANSACTION READ ONLY`, Args: []interface{}{}},
+			{SQL: `UPDATE t1 SET a = ?`, Args: []interface{}{1}},
+			{SQL: `COMMIT`, Args: []interface{}{}},
+		}, q.Batch())
+
+		q = qb.Begin().IsolationLevel(qb.Serializable).DialectOption(qb.DialectMssql)
+		require.Equal(t, []qb.BatchQuery{
+			{SQL: `SET TRANSACTION ISOLATION LEVEL SERIALIZABLE`, Args: []interface{}{}},
+			{SQL: `BEGIN TRANSACTION`, Args: []interface{}{}},
+		}, q.Batch())
+
+		q = qb.Begin().IsolationLevel(qb.Serializable).DialectOption(qb.DialectPq)
+		require.Equal(t, []qb.BatchQuery{
+			{SQL: `BEGIN ISOLATION LEVEL SERIALIZABLE`, Args: []interface{}{}},
+		}, q.Batch())
+	})
+}
+
+func TestQuery_SQL(t *testing.T) {
+	t.Run("reuse after render", func(t *testing.T) {
+		base := qb.WithDialectPQ().Select("*").From("t1")
+		require.Equal(t, "SELECT * FROM t1", base.SQL())
+
+		q := base.Where(qb.Pred("a = ?", 1))
+		require.Equal(t, "SELECT * FROM t1 WHERE a = $1", q.SQL())
+	})
+
+	t.Run("question marks in SQL", func(t *testing.T) {
+		q := qb.WithDialectPQ().Select("*").From("t1").Where(qb.Pred("a = ? AND b = ?", qb.Lit(`'?'`), 1))
+		require.Equal(t, "SELECT * FROM t1 WHERE a = '?' AND b = $1", q.SQL())
+	})
+
+	q := qb.WithDialectMssql().Select("*").From("t1").Where(qb.Pred("a = ? AND b = ?", 1, 2))
+	sql := "SELECT * FROM t1 WHERE a = @p1 AND b = @p2"
+
+	t.Run("AppendSQL", func(t *testing.T) {
+		require.Equal(t, "BEGIN; "+sql, string(q.AppendSQL([]byte("BEGIN; "))))
+	})
+
+	t.Run("WriteTo", func(t *testing.T) {
+		var b strings.Builder
+		n, err := q.WriteTo(&b)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(sql)), n)
+		require.Equal(t, sql, b.String())
+	})
+
+	t.Run("Build", func(t *testing.T) {
+		s, args := q.Build()
+		require.Equal(t, sql, s)
+		require.Equal(t, []interface{}{1, 2}, args)
+
+		s, args, err := q.TryBuild()
+		require.NoError(t, err)
+		require.Equal(t, sql, s)
+		require.Equal(t, []interface{}{1, 2}, args)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		q := qb.Strict().Select("*").From("t1; DROP TABLE t2")
+		_, _, err := q.TryBuild()
+		require.EqualError(t, err, `qb: strict: "t1; DROP TABLE t2" is not a valid table; use qb.Raw to write raw SQL`)
+
+		var b strings.Builder
+		_, err = q.WriteTo(&b)
+		require.Error(t, err)
+		require.Empty(t, b.String())
+
+		_, err = qb.Multiple(qb.Select("*").From("t1"), q).TryBatch()
+		require.Error(t, err)
+		require.Panics(t, func() { q.Build() })
+	})
+}
@@ -0,0 +1,500 @@
+// Package qbfilter translates the filters of API requests into qb predicates.
+//
+// Filters are checked against a Schema, which declares the fields that may be
+// filtered by, their columns and their types. They are given either in a
+// query string:
+//  status=in:active,pending&age=gte:18&name=ilike:bob%
+// or as JSON, which can also combine filters with "and" and "or":
+//  {"status": {"in": ["active", "pending"]},
+//   "or": [{"age": {"gte": 18}}, {"name": {"ilike": "bob%"}}]}
+// The values of a filter are always passed to the database as arguments.
+package qbfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tetratom/qb"
+)
+
+const (
+	DefaultMaxDepth = 4
+	DefaultMaxTerms = 32
+)
+
+// Type is the type of the values of a field.
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	// Time values are formatted as RFC 3339.
+	Time
+)
+
+func (t Type) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case Time:
+		return "time"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// Op is a comparison operator of a filter.
+type Op string
+
+const (
+	Eq    Op = "eq"    // column = value
+	Ne    Op = "ne"    // column <> value
+	Lt    Op = "lt"    // column < value
+	Lte   Op = "lte"   // column <= value
+	Gt    Op = "gt"    // column > value
+	Gte   Op = "gte"   // column >= value
+	In    Op = "in"    // column IN (values)
+	Nin   Op = "nin"   // column NOT IN (values)
+	Like  Op = "like"  // column LIKE pattern
+	ILike Op = "ilike" // lower(column) LIKE lower(pattern)
+	Null  Op = "null"  // column IS NULL, or IS NOT NULL if the value is false
+)
+
+var allOps = []Op{Eq, Ne, Lt, Lte, Gt, Gte, In, Nin, Like, ILike, Null}
+
+// ops returns the operators which apply to values of type t.
+func (t Type) ops() []Op {
+	switch t {
+	case String:
+		return []Op{Eq, Ne, Lt, Lte, Gt, Gte, In, Nin, Like, ILike}
+	case Bool:
+		return []Op{Eq, Ne}
+	default:
+		return []Op{Eq, Ne, Lt, Lte, Gt, Gte, In, Nin}
+	}
+}
+
+// Field is a field which may be filtered by.
+type Field struct {
+	// Column is the column or expression which the field is compared with.
+	Column string
+	Type   Type
+
+	// Nullable fields can be filtered with the null operator.
+	Nullable bool
+
+	// Ops restricts the operators which may be used with the field. All the
+	// operators which apply to its type may be used if Ops is empty.
+	Ops []Op
+}
+
+func (f Field) allows(op Op) bool {
+	if op == Null {
+		return f.Nullable
+	}
+	return hasOp(f.Type.ops(), op) && (len(f.Ops) == 0 || hasOp(f.Ops, op))
+}
+
+func hasOp(ops []Op, op Op) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema declares the fields of the filters of an endpoint.
+type Schema struct {
+	Fields map[string]Field
+
+	// MaxDepth limits the nesting of "and" and "or" in JSON filters, and
+	// MaxTerms the number of comparisons in a filter, where each value of an
+	// "in" or "nin" list counts as a comparison. They default to
+	// DefaultMaxDepth and DefaultMaxTerms.
+	MaxDepth int
+	MaxTerms int
+}
+
+func (s Schema) maxDepth() int {
+	if s.MaxDepth > 0 {
+		return s.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+func (s Schema) maxTerms() int {
+	if s.MaxTerms > 0 {
+		return s.MaxTerms
+	}
+	return DefaultMaxTerms
+}
+
+// node is a parsed filter: either a comparison, or a group of filters which
+// are combined with AND or OR.
+type node struct {
+	expr     string
+	args     []interface{}
+	or       bool
+	children []node
+}
+
+func (n node) predicate() qb.Predicate {
+	var p qb.Predicate
+	for _, c := range n.children {
+		switch {
+		case c.expr != "" && n.or:
+			p = p.Or(c.expr, c.args...)
+		case c.expr != "":
+			p = p.And(c.expr, c.args...)
+		case n.or:
+			p = p.OrP(c.predicate())
+		default:
+			p = p.AndP(c.predicate())
+		}
+	}
+	return p
+}
+
+// add adds c to the children of n, or the children of c if they are combined
+// in the same way.
+func (n *node) add(c node) {
+	switch {
+	case c.expr != "":
+		n.children = append(n.children, c)
+	case len(c.children) == 1 || c.or == n.or:
+		n.children = append(n.children, c.children...)
+	case len(c.children) > 1:
+		n.children = append(n.children, c)
+	}
+}
+
+// parser counts the terms of a filter.
+type parser struct {
+	s     Schema
+	terms int
+}
+
+func (p *parser) term(name string, op Op, values []interface{}) (node, error) {
+	if op == In || op == Nin {
+		p.terms += len(values)
+	} else {
+		p.terms++
+	}
+	if p.terms > p.s.maxTerms() {
+		return node{}, fmt.Errorf("qbfilter: too many terms; at most %d are allowed", p.s.maxTerms())
+	}
+
+	column := p.s.Fields[name].Column
+	switch op {
+	case Eq:
+		return node{expr: column + " = ?", args: values}, nil
+	case Ne:
+		return node{expr: column + " <> ?", args: values}, nil
+	case Lt:
+		return node{expr: column + " < ?", args: values}, nil
+	case Lte:
+		return node{expr: column + " <= ?", args: values}, nil
+	case Gt:
+		return node{expr: column + " > ?", args: values}, nil
+	case Gte:
+		return node{expr: column + " >= ?", args: values}, nil
+	case In, Nin:
+		if len(values) == 0 && op == In {
+			return node{expr: "1 = 0"}, nil
+		} else if len(values) == 0 {
+			return node{expr: "1 = 1"}, nil
+		}
+
+		expr := column + " IN (?" + strings.Repeat(", ?", len(values)-1) + ")"
+		if op == Nin {
+			expr = column + " NOT IN (?" + strings.Repeat(", ?", len(values)-1) + ")"
+		}
+		return node{expr: expr, args: values}, nil
+	case Like:
+		return node{expr: column + " LIKE ?", args: values}, nil
+	case ILike:
+		return node{expr: "lower(" + column + ") LIKE lower(?)", args: values}, nil
+	default:
+		if values[0] == true {
+			return node{expr: column + " IS NULL"}, nil
+		}
+		return node{expr: column + " IS NOT NULL"}, nil
+	}
+}
+
+// field returns the field with the given name, if it may be used with op.
+func (p *parser) field(name string, op Op) (Field, error) {
+	f, ok := p.s.Fields[name]
+	if !ok {
+		return Field{}, fmt.Errorf("qbfilter: unknown field %q", name)
+	}
+	if !hasOp(allOps, op) {
+		return Field{}, fmt.Errorf("qbfilter: unknown operator %q", op)
+	}
+	if !f.allows(op) {
+		return Field{}, fmt.Errorf("qbfilter: operator %q is not allowed for field %q", op, name)
+	}
+	return f, nil
+}
+
+// Parses the filters in a query string. Each filter is a field and a value,
+// optionally preceded by an operator; the values of the in and nin operators
+// are separated by commas:
+//  status=in:active,pending&age=gte:18&name=ilike:bob%&deleted_at=null:true
+// A field without an operator is compared with eq. The filters, including
+// several filters on the same field, are combined with AND. Parameters which
+// are not fields of the schema, such as the sort order of a request, are
+// ignored.
+func (s Schema) ParseQuery(values url.Values) (qb.Predicate, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if _, ok := s.Fields[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	p := parser{s: s}
+	var n node
+	for _, key := range keys {
+		for _, value := range values[key] {
+			op := Eq
+			if i := strings.IndexByte(value, ':'); i >= 0 && hasOp(allOps, Op(value[:i])) {
+				op, value = Op(value[:i]), value[i+1:]
+			}
+
+			f, err := p.field(key, op)
+			if err != nil {
+				return qb.Predicate{}, err
+			}
+
+			texts := []string{value}
+			if op == In || op == Nin {
+				texts = strings.Split(value, ",")
+			}
+
+			args := make([]interface{}, len(texts))
+			for i, text := range texts {
+				args[i], err = parseValue(f, op, text)
+				if err != nil {
+					return qb.Predicate{}, fmt.Errorf("qbfilter: field %q: %w", key, err)
+				}
+			}
+
+			t, err := p.term(key, op, args)
+			if err != nil {
+				return qb.Predicate{}, err
+			}
+			n.add(t)
+		}
+	}
+	return n.predicate(), nil
+}
+
+// parseValue parses the text of a value in a query string.
+func parseValue(f Field, op Op, text string) (interface{}, error) {
+	t := f.Type
+	if op == Null {
+		t = Bool
+	}
+
+	switch t {
+	case Int:
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", text)
+		}
+		return v, nil
+	case Float:
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", text)
+		}
+		return v, nil
+	case Bool:
+		v, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", text)
+		}
+		return v, nil
+	case Time:
+		v, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", text)
+		}
+		return v, nil
+	default:
+		return text, nil
+	}
+}
+
+// Parses a filter in JSON. A filter is an object whose keys are fields, or
+// "and" or "or" with an array of filters. A field is compared either with a
+// value, with eq, or with an object of operators and their values; the in and
+// nin operators take arrays:
+//  {"status": "active", "age": {"gte": 18, "lt": 65}}
+//  {"or": [{"status": {"in": ["active", "pending"]}}, {"deleted_at": {"null": false}}]}
+// The keys of an object are combined with AND. Unlike in query strings, keys
+// which are not fields of the schema are an error.
+func (s Schema) ParseJSON(data []byte) (qb.Predicate, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var filter map[string]interface{}
+	if err := d.Decode(&filter); err != nil {
+		return qb.Predicate{}, fmt.Errorf("qbfilter: invalid filter: %w", err)
+	}
+
+	p := parser{s: s}
+	n, err := p.object(filter, 0)
+	if err != nil {
+		return qb.Predicate{}, err
+	}
+	return n.predicate(), nil
+}
+
+func (p *parser) object(filter map[string]interface{}, depth int) (node, error) {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var n node
+	for _, key := range keys {
+		switch key {
+		case "and", "or":
+			g, err := p.group(key, filter[key], depth+1)
+			if err != nil {
+				return node{}, err
+			}
+			n.add(g)
+
+		default:
+			ops, ok := filter[key].(map[string]interface{})
+			if !ok {
+				ops = map[string]interface{}{string(Eq): filter[key]}
+			}
+
+			names := make([]string, 0, len(ops))
+			for op := range ops {
+				names = append(names, op)
+			}
+			sort.Strings(names)
+
+			for _, op := range names {
+				t, err := p.jsonTerm(key, Op(op), ops[op])
+				if err != nil {
+					return node{}, err
+				}
+				n.add(t)
+			}
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) group(key string, v interface{}, depth int) (node, error) {
+	if depth > p.s.maxDepth() {
+		return node{}, fmt.Errorf("qbfilter: filter is nested too deeply; at most %d levels are allowed", p.s.maxDepth())
+	}
+
+	filters, ok := v.([]interface{})
+	if !ok {
+		return node{}, fmt.Errorf("qbfilter: %q must be an array of filters", key)
+	}
+
+	g := node{or: key == "or"}
+	for _, f := range filters {
+		filter, ok := f.(map[string]interface{})
+		if !ok {
+			return node{}, fmt.Errorf("qbfilter: %q must be an array of filters", key)
+		}
+
+		n, err := p.object(filter, depth)
+		if err != nil {
+			return node{}, err
+		}
+
+		g.add(n)
+	}
+	return g, nil
+}
+
+func (p *parser) jsonTerm(name string, op Op, v interface{}) (node, error) {
+	f, err := p.field(name, op)
+	if err != nil {
+		return node{}, err
+	}
+
+	values := []interface{}{v}
+	if op == In || op == Nin {
+		var ok bool
+		if values, ok = v.([]interface{}); !ok {
+			return node{}, fmt.Errorf("qbfilter: field %q: %s takes an array", name, op)
+		}
+	}
+
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i], err = jsonValue(f, op, v)
+		if err != nil {
+			return node{}, fmt.Errorf("qbfilter: field %q: %w", name, err)
+		}
+	}
+	return p.term(name, op, args)
+}
+
+// jsonValue converts a value decoded from JSON to the type of a field.
+func jsonValue(f Field, op Op, v interface{}) (interface{}, error) {
+	t := f.Type
+	if op == Null {
+		t = Bool
+	}
+
+	switch x := v.(type) {
+	case json.Number:
+		switch t {
+		case Int:
+			n, err := x.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("invalid int %s", x)
+			}
+			return n, nil
+		case Float:
+			return x.Float64()
+		}
+	case bool:
+		if t == Bool {
+			return x, nil
+		}
+	case string:
+		switch t {
+		case String:
+			return x, nil
+		case Time:
+			return parseValue(f, op, x)
+		}
+	}
+	return nil, fmt.Errorf("invalid %s %s", t, jsonText(v))
+}
+
+func jsonText(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,187 @@
+package qbfilter_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+	"github.com/tetratom/qb/qbfilter"
+)
+
+var schema = qbfilter.Schema{
+	Fields: map[string]qbfilter.Field{
+		"status":     {Column: "u.status", Type: qbfilter.String, Ops: []qbfilter.Op{qbfilter.Eq, qbfilter.In}},
+		"age":        {Column: "u.age", Type: qbfilter.Int},
+		"name":       {Column: "u.name", Type: qbfilter.String},
+		"score":      {Column: "u.score", Type: qbfilter.Float},
+		"admin":      {Column: "u.admin", Type: qbfilter.Bool},
+		"created_at": {Column: "u.created_at", Type: qbfilter.Time},
+		"deleted_at": {Column: "u.deleted_at", Type: qbfilter.Time, Nullable: true},
+	},
+	MaxDepth: 2,
+	MaxTerms: 5,
+}
+
+func TestSchema_ParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		expr  string
+		args  []interface{}
+		err   string
+	}{
+		{
+			query: "status=in:active,pending&age=gte:18&name=ilike:bob%25&sort=-name",
+			expr:  `SELECT * FROM users u WHERE u.age >= $1 AND lower(u.name) LIKE lower( $2 ) AND u.status IN ( $3 , $4 )`,
+			args:  []interface{}{int64(18), "bob%", "active", "pending"},
+		},
+		{
+			query: "age=gt:18&age=lt:65&admin=true&score=ne:0.5",
+			expr:  `SELECT * FROM users u WHERE u.admin = $1 AND u.age > $2 AND u.age < $3 AND u.score <> $4`,
+			args:  []interface{}{true, int64(18), int64(65), 0.5},
+		},
+		{
+			query: "name=a:b&deleted_at=null:false",
+			expr:  `SELECT * FROM users u WHERE u.deleted_at IS NOT NULL AND u.name = $1`,
+			args:  []interface{}{"a:b"},
+		},
+		{
+			query: "created_at=gte:2020-01-02T03:04:05Z",
+			expr:  `SELECT * FROM users u WHERE u.created_at >= $1`,
+			args:  []interface{}{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+		{
+			query: "sort=name",
+			expr:  `SELECT * FROM users u`,
+			args:  []interface{}{},
+		},
+		{query: "status=like:a%25", err: `qbfilter: operator "like" is not allowed for field "status"`},
+		{query: "admin=gt:true", err: `qbfilter: operator "gt" is not allowed for field "admin"`},
+		{query: "age=null:true", err: `qbfilter: operator "null" is not allowed for field "age"`},
+		{query: "age=gte:old", err: `qbfilter: field "age": invalid int "old"`},
+		{query: "created_at=yesterday", err: `qbfilter: field "created_at": invalid time "yesterday"`},
+		{query: "age=1&age=2&age=3&age=4&age=5&age=6", err: `qbfilter: too many terms; at most 5 are allowed`},
+		{query: "age=in:1,2,3,4,5,6", err: `qbfilter: too many terms; at most 5 are allowed`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			p, err := schema.ParseQuery(values)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			q := qb.WithDialectPQ().Select("*").From("users u").Where(p)
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+}
+
+func TestSchema_ParseJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		expr   string
+		args   []interface{}
+		err    string
+	}{
+		{
+			name:   "fields",
+			filter: `{"status": "active", "age": {"gte": 18, "lt": 65}}`,
+			expr:   `SELECT * FROM users u WHERE u.age >= $1 AND u.age < $2 AND u.status = $3`,
+			args:   []interface{}{int64(18), int64(65), "active"},
+		},
+		{
+			name:   "or",
+			filter: `{"status": {"in": ["active", "pending"]}, "or": [{"age": {"gte": 18}}, {"name": {"ilike": "bob%"}, "admin": true}]}`,
+			expr:   `SELECT * FROM users u WHERE ( u.age >= $1 OR ( u.admin = $2 AND lower(u.name) LIKE lower( $3 ) ) ) AND u.status IN ( $4 , $5 )`,
+			args:   []interface{}{int64(18), true, "bob%", "active", "pending"},
+		},
+		{
+			name:   "and",
+			filter: `{"and": [{"score": {"gt": 1.5}}, {"deleted_at": {"null": true}}]}`,
+			expr:   `SELECT * FROM users u WHERE u.score > $1 AND u.deleted_at IS NULL`,
+			args:   []interface{}{1.5},
+		},
+		{
+			name:   "single or",
+			filter: `{"or": [{"age": 1}], "name": "x"}`,
+			expr:   `SELECT * FROM users u WHERE u.name = $1 AND u.age = $2`,
+			args:   []interface{}{"x", int64(1)},
+		},
+		{
+			name:   "empty",
+			filter: `{"or": []}`,
+			expr:   `SELECT * FROM users u`,
+			args:   []interface{}{},
+		},
+		{
+			name:   "unknown field",
+			filter: `{"password": "x"}`,
+			err:    `qbfilter: unknown field "password"`,
+		},
+		{
+			name:   "unknown operator",
+			filter: `{"age": {"between": [1, 2]}}`,
+			err:    `qbfilter: unknown operator "between"`,
+		},
+		{
+			name:   "type",
+			filter: `{"age": "18"}`,
+			err:    `qbfilter: field "age": invalid int "18"`,
+		},
+		{
+			name:   "fraction",
+			filter: `{"age": 1.5}`,
+			err:    `qbfilter: field "age": invalid int 1.5`,
+		},
+		{
+			name:   "in",
+			filter: `{"status": {"in": "active"}}`,
+			err:    `qbfilter: field "status": in takes an array`,
+		},
+		{
+			name:   "group",
+			filter: `{"or": {"age": 1}}`,
+			err:    `qbfilter: "or" must be an array of filters`,
+		},
+		{
+			name:   "depth",
+			filter: `{"or": [{"and": [{"or": [{"age": 1}]}]}]}`,
+			err:    `qbfilter: filter is nested too deeply; at most 2 levels are allowed`,
+		},
+		{
+			name:   "terms",
+			filter: `{"or": [{"age": 1}, {"age": 2}, {"age": 3}], "and": [{"age": 4}, {"age": 5}, {"age": 6}]}`,
+			err:    `qbfilter: too many terms; at most 5 are allowed`,
+		},
+		{
+			name:   "syntax",
+			filter: `{"age": `,
+			err:    `qbfilter: invalid filter: unexpected EOF`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := schema.ParseJSON([]byte(tt.filter))
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			q := qb.WithDialectPQ().Select("*").From("users u").Where(p)
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+}
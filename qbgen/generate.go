@@ -0,0 +1,188 @@
+package qbgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Generate returns the source of a Go package for every table in the schema,
+// keyed by its path relative to the output directory. Each package declares
+// the table's name as Table, a qb.Column of the Go type of every column, and
+// Columns listing all of them in order:
+//  package users
+//
+//  const Table = "users"
+//
+//  var (
+//  	ID       = qb.NewColumn[int64]("id")
+//  	Email    = qb.NewColumn[string]("email")
+//  	Nickname = qb.NewColumn[*string]("nickname")
+//  )
+// Nullable columns have pointer types, and columns of types which have no Go
+// counterpart, such as arrays, are of type interface{}.
+// It is an error for two tables to have the same package name.
+func Generate(s Schema) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	tables := map[string]string{}
+	for _, t := range s.Tables {
+		pkg := packageName(t.Name)
+		if other, ok := tables[pkg]; ok {
+			if other == t.Name {
+				return nil, fmt.Errorf("qbgen: table %s is defined more than once", t.Name)
+			}
+			return nil, fmt.Errorf("qbgen: tables %s and %s are both generated as package %s", other, t.Name, pkg)
+		}
+		tables[pkg] = t.Name
+
+		src, err := generateTable(pkg, t)
+		if err != nil {
+			return nil, fmt.Errorf("qbgen: table %s: %v", t.Name, err)
+		}
+
+		files[path.Join(pkg, pkg+".go")] = src
+	}
+	return files, nil
+}
+
+func generateTable(pkg string, t Table) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by qbgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "// Package %s describes the %s table.\n", pkg, t.Name)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	types := make([]string, len(t.Columns))
+	usesTime := false
+	for i, c := range t.Columns {
+		types[i] = goType(c)
+		usesTime = usesTime || strings.Contains(types[i], "time.")
+	}
+
+	if usesTime {
+		fmt.Fprintf(&b, "import (\n\t\"time\"\n\n\t\"github.com/tetratom/qb\"\n)\n\n")
+	} else {
+		fmt.Fprintf(&b, "import \"github.com/tetratom/qb\"\n\n")
+	}
+	fmt.Fprintf(&b, "const Table = %q\n\n", t.Name)
+
+	names := make([]string, len(t.Columns))
+	cols := make([]string, len(t.Columns))
+	used := map[string]bool{"Table": true, "Columns": true}
+	fmt.Fprintf(&b, "var (\n")
+	for i, c := range t.Columns {
+		name := identifier(c.Name)
+		for used[name] {
+			name += "_"
+		}
+		used[name] = true
+		names[i] = name
+		cols[i] = name + ".Col()"
+
+		nullable := ""
+		if c.Nullable {
+			nullable = ", nullable"
+		}
+		fmt.Fprintf(&b, "\t// %s %s%s\n", c.Name, strings.ToLower(c.Type), nullable)
+		fmt.Fprintf(&b, "\t%s = qb.NewColumn[%s](%q)\n", name, types[i], c.Name)
+	}
+	fmt.Fprintf(&b, ")\n\n")
+
+	fmt.Fprintf(&b, "var Columns = []qb.Col{%s}\n", strings.Join(cols, ", "))
+	return format.Source(b.Bytes())
+}
+
+var goTypes = map[string]string{
+	"bigint": "int64", "int8": "int64", "bigserial": "int64", "serial8": "int64",
+	"integer": "int32", "int": "int32", "int4": "int32", "serial": "int32", "serial4": "int32", "mediumint": "int32",
+	"smallint": "int16", "int2": "int16", "smallserial": "int16", "serial2": "int16", "tinyint": "int16",
+	"boolean": "bool", "bool": "bool", "bit": "bool",
+	"real": "float32", "float4": "float32",
+	"double precision": "float64", "double": "float64", "float8": "float64", "float": "float64",
+	"numeric": "string", "decimal": "string", "money": "string",
+	"text": "string", "varchar": "string", "character varying": "string", "char": "string", "character": "string",
+	"nvarchar": "string", "nchar": "string", "ntext": "string", "citext": "string", "uuid": "string",
+	"uniqueidentifier": "string", "varchar2": "string", "nvarchar2": "string", "clob": "string",
+	"tinytext": "string", "mediumtext": "string", "longtext": "string", "enum": "string", "time": "string",
+	"time without time zone": "string", "time with time zone": "string", "timetz": "string", "interval": "string",
+	"bytea": "[]byte", "blob": "[]byte", "binary": "[]byte", "varbinary": "[]byte", "tinyblob": "[]byte",
+	"mediumblob": "[]byte", "longblob": "[]byte", "json": "[]byte", "jsonb": "[]byte",
+	"date": "time.Time", "datetime": "time.Time", "datetime2": "time.Time", "datetimeoffset": "time.Time",
+	"timestamp": "time.Time", "timestamptz": "time.Time", "timestamp without time zone": "time.Time",
+	"timestamp with time zone": "time.Time",
+}
+
+var typeArgsPattern = regexp.MustCompile(`\([^)]*\)|\bunsigned\b`)
+
+// goType returns the Go type of the values of a column: that of its SQL type,
+// as a pointer if the column is nullable, or interface{} if the type is
+// unknown.
+func goType(c Column) string {
+	typ := strings.ToLower(typeArgsPattern.ReplaceAllString(c.Type, ""))
+	typ = strings.Join(strings.Fields(typ), " ")
+
+	t, ok := goTypes[typ]
+	if !ok {
+		return "interface{}"
+	}
+
+	if c.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+// packageName returns a Go package name for a table.
+func packageName(table string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(table) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "t" + name
+	}
+
+	if token.Lookup(name).IsKeyword() {
+		name += "table"
+	}
+	return name
+}
+
+var initialisms = map[string]bool{
+	"ID": true, "URL": true, "URI": true, "UUID": true, "IP": true,
+	"HTTP": true, "JSON": true, "SQL": true, "API": true, "UID": true,
+}
+
+// identifier returns an exported Go identifier for a column, e.g. UserID for
+// user_id.
+func identifier(column string) string {
+	var b strings.Builder
+	words := strings.FieldsFunc(column, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, word := range words {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+
+		rs := []rune(strings.ToLower(word))
+		rs[0] = unicode.ToUpper(rs[0])
+		b.WriteString(string(rs))
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "C" + name
+	}
+	return name
+}
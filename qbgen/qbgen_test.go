@@ -0,0 +1,176 @@
+package qbgen_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb/qbgen"
+)
+
+const ddl = `
+-- users of the application
+CREATE TABLE IF NOT EXISTS public."users" (
+	id BIGSERIAL PRIMARY KEY,
+	email VARCHAR(255) NOT NULL UNIQUE,
+	display_name TEXT,
+	price NUMERIC(10, 2) DEFAULT 0,
+	/* the owning org, (see orgs) */
+	org_id BIGINT NOT NULL REFERENCES orgs (id),
+	CONSTRAINT users_email_check CHECK (email <> ''),
+	UNIQUE (org_id, email)
+);
+
+CREATE INDEX users_email ON users (email);
+
+CREATE TABLE type (
+	"table" TEXT NOT NULL,
+	url TEXT,
+	created_at TIMESTAMP(3) WITH TIME ZONE NOT NULL,
+	tags TEXT[]
+);
+`
+
+func TestParseDDL(t *testing.T) {
+	s, err := qbgen.ParseDDL(ddl)
+	require.NoError(t, err)
+	require.Equal(t, qbgen.Schema{
+		Tables: []qbgen.Table{
+			{
+				Name: "users",
+				Columns: []qbgen.Column{
+					{Name: "id", Type: "BIGSERIAL", Nullable: false},
+					{Name: "email", Type: "VARCHAR(255)", Nullable: false},
+					{Name: "display_name", Type: "TEXT", Nullable: true},
+					{Name: "price", Type: "NUMERIC(10, 2)", Nullable: true},
+					{Name: "org_id", Type: "BIGINT", Nullable: false},
+				},
+			},
+			{
+				Name: "type",
+				Columns: []qbgen.Column{
+					{Name: "table", Type: "TEXT", Nullable: false},
+					{Name: "url", Type: "TEXT", Nullable: true},
+					{Name: "created_at", Type: "TIMESTAMP(3) WITH TIME ZONE", Nullable: false},
+					{Name: "tags", Type: "TEXT[]", Nullable: true},
+				},
+			},
+		},
+	}, s)
+
+	_, err = qbgen.ParseDDL("CREATE TABLE t (a TEXT")
+	require.EqualError(t, err, "qbgen: table t: unbalanced parentheses")
+}
+
+func TestSchema_ApplyDDL(t *testing.T) {
+	var s qbgen.Schema
+	require.NoError(t, s.ApplyDDL(`
+		CREATE TABLE users (id BIGINT PRIMARY KEY, name TEXT, legacy TEXT);
+		CREATE TABLE sessions (id BIGINT);
+	`))
+	require.NoError(t, s.ApplyDDL(`
+		ALTER TABLE users ADD COLUMN email TEXT NOT NULL, DROP COLUMN legacy;
+		ALTER TABLE public.users RENAME COLUMN name TO display_name;
+		ALTER TABLE users ALTER COLUMN id TYPE NUMERIC(20, 0) USING id::numeric;
+		ALTER TABLE users ALTER COLUMN display_name SET NOT NULL;
+		ALTER TABLE users ADD CONSTRAINT users_email UNIQUE (email);
+		ALTER TABLE users RENAME TO accounts;
+		DROP TABLE IF EXISTS sessions CASCADE;
+	`))
+	require.Equal(t, qbgen.Schema{
+		Tables: []qbgen.Table{
+			{
+				Name: "accounts",
+				Columns: []qbgen.Column{
+					{Name: "id", Type: "NUMERIC(20, 0)", Nullable: false},
+					{Name: "display_name", Type: "TEXT", Nullable: false},
+					{Name: "email", Type: "TEXT", Nullable: false},
+				},
+			},
+		},
+	}, s)
+
+	tests := []struct {
+		ddl string
+		err string
+	}{
+		{"CREATE TABLE accounts (id BIGINT)", "qbgen: table accounts is defined more than once"},
+		{"ALTER TABLE users ADD COLUMN a TEXT", "qbgen: ALTER TABLE of undefined table users"},
+		{"ALTER TABLE accounts RENAME COLUMN a TO b", "qbgen: table accounts: RENAME of undefined column a"},
+		{"ALTER TABLE accounts MODIFY email VARCHAR(255)", `qbgen: table accounts: unsupported ALTER TABLE action "MODIFY email VARCHAR(255)"`},
+	}
+
+	for _, tt := range tests {
+		s := qbgen.Schema{Tables: append([]qbgen.Table(nil), s.Tables...)}
+		require.EqualError(t, s.ApplyDDL(tt.ddl), tt.err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := qbgen.ParseDDL(ddl)
+	require.NoError(t, err)
+
+	files, err := qbgen.Generate(s)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	require.Equal(t, `// Code generated by qbgen. DO NOT EDIT.
+
+// Package users describes the users table.
+package users
+
+import "github.com/tetratom/qb"
+
+const Table = "users"
+
+var (
+	// id bigserial
+	ID = qb.NewColumn[int64]("id")
+	// email varchar(255)
+	Email = qb.NewColumn[string]("email")
+	// display_name text, nullable
+	DisplayName = qb.NewColumn[*string]("display_name")
+	// price numeric(10, 2), nullable
+	Price = qb.NewColumn[*string]("price")
+	// org_id bigint
+	OrgID = qb.NewColumn[int64]("org_id")
+)
+
+var Columns = []qb.Col{ID.Col(), Email.Col(), DisplayName.Col(), Price.Col(), OrgID.Col()}
+`, string(files["users/users.go"]))
+
+	require.Equal(t, `// Code generated by qbgen. DO NOT EDIT.
+
+// Package typetable describes the type table.
+package typetable
+
+import (
+	"time"
+
+	"github.com/tetratom/qb"
+)
+
+const Table = "type"
+
+var (
+	// table text
+	Table_ = qb.NewColumn[string]("table")
+	// url text, nullable
+	URL = qb.NewColumn[*string]("url")
+	// created_at timestamp(3) with time zone
+	CreatedAt = qb.NewColumn[time.Time]("created_at")
+	// tags text[], nullable
+	Tags = qb.NewColumn[interface{}]("tags")
+)
+
+var Columns = []qb.Col{Table_.Col(), URL.Col(), CreatedAt.Col(), Tags.Col()}
+`, string(files["typetable/typetable.go"]))
+}
+
+func TestGenerate_collision(t *testing.T) {
+	_, err := qbgen.Generate(qbgen.Schema{Tables: []qbgen.Table{{Name: "user_roles"}, {Name: "userroles"}}})
+	require.EqualError(t, err, "qbgen: tables user_roles and userroles are both generated as package userroles")
+
+	_, err = qbgen.Generate(qbgen.Schema{Tables: []qbgen.Table{{Name: "users"}, {Name: "users"}}})
+	require.EqualError(t, err, "qbgen: table users is defined more than once")
+}
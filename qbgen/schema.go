@@ -0,0 +1,323 @@
+// Package qbgen generates Go packages describing the tables and columns of a
+// database schema, for use with qb. It is the library behind cmd/qbgen.
+package qbgen
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tetratom/qb"
+)
+
+type Schema struct {
+	Tables []Table
+}
+
+type Table struct {
+	Name    string
+	Columns []Column
+}
+
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+func (s *Schema) table(name string) *Table {
+	for i := range s.Tables {
+		if s.Tables[i].Name == name {
+			return &s.Tables[i]
+		}
+	}
+
+	s.Tables = append(s.Tables, Table{Name: name})
+	return &s.Tables[len(s.Tables)-1]
+}
+
+// LoadInformationSchema reads the tables of the given schema (e.g. "public")
+// from the information_schema of db. The caller is responsible for linking the
+// database driver.
+func LoadInformationSchema(ctx context.Context, db *sql.DB, dialect qb.Dialect, schema string) (Schema, error) {
+	q := qb.
+		Select("table_name", "column_name", "data_type", "is_nullable").
+		From("information_schema.columns").
+		Where(qb.And("table_schema = ?", schema)).
+		OrderBy("table_name", "ordinal_position").
+		DialectOption(dialect)
+
+	query, args, err := q.TryBuild()
+	if err != nil {
+		return Schema{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Schema{}, err
+	}
+	defer rows.Close()
+
+	var s Schema
+	for rows.Next() {
+		var table, nullable string
+		var c Column
+		if err := rows.Scan(&table, &c.Name, &c.Type, &nullable); err != nil {
+			return Schema{}, err
+		}
+
+		c.Nullable = strings.EqualFold(nullable, "YES")
+		t := s.table(table)
+		t.Columns = append(t.Columns, c)
+	}
+	return s, rows.Err()
+}
+
+var (
+	commentPattern     = regexp.MustCompile(`(?m)--.*$|(?s)/\*.*?\*/`)
+	createTablePattern = regexp.MustCompile(`(?i)^CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMPORARY|TEMP)\s+|UNLOGGED\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)\s*\(`)
+	alterTablePattern  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(\S+)\s+(.*)$`)
+	dropTablePattern   = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(.*?)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	constraintPattern  = regexp.MustCompile(`(?i)^(CONSTRAINT|PRIMARY\s+KEY|FOREIGN\s+KEY|UNIQUE|CHECK|EXCLUDE|INDEX|KEY|FULLTEXT|SPATIAL)\b`)
+	notNullPattern     = regexp.MustCompile(`(?i)\bNOT\s+NULL\b|\bPRIMARY\s+KEY\b`)
+	typeEndPattern     = regexp.MustCompile(`(?i)\s+(NOT|NULL|PRIMARY|UNIQUE|DEFAULT|REFERENCES|CHECK|CONSTRAINT|COLLATE|GENERATED|AUTO_INCREMENT|IDENTITY)\b`)
+
+	addColumnPattern    = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(.*)$`)
+	dropColumnPattern   = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?(\S+)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	dropOtherPattern    = regexp.MustCompile(`(?i)^DROP\s+(CONSTRAINT|PRIMARY\s+KEY|FOREIGN\s+KEY|INDEX|KEY|CHECK)\b`)
+	renameTablePattern  = regexp.MustCompile(`(?is)^RENAME\s+TO\s+(\S+)$`)
+	renameColumnPattern = regexp.MustCompile(`(?is)^RENAME\s+(?:COLUMN\s+)?(\S+)\s+TO\s+(\S+)$`)
+	alterTypePattern    = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?(\S+)\s+(?:SET\s+DATA\s+)?TYPE\s+(.*?)(?:\s+USING\s+.*)?$`)
+	alterNullPattern    = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?(\S+)\s+(SET|DROP)\s+NOT\s+NULL$`)
+	alterDefaultPattern = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?(\S+)\s+(?:SET\s+DEFAULT\s+.*|DROP\s+DEFAULT)$`)
+)
+
+// ParseDDL reads the tables defined by the CREATE TABLE statements in src,
+// as altered by the ALTER TABLE and DROP TABLE statements which follow them.
+// Other statements are ignored.
+func ParseDDL(src string) (Schema, error) {
+	var s Schema
+	if err := s.ApplyDDL(src); err != nil {
+		return Schema{}, err
+	}
+	return s, nil
+}
+
+// ApplyDDL applies the CREATE TABLE, ALTER TABLE and DROP TABLE statements in
+// src to the schema, such as those of a sequence of migrations read in turn.
+// Other statements are ignored. It is an error to create a table which is
+// already defined, to alter a table which is not, and to alter the columns of
+// a table in a way other than adding, dropping, renaming and retyping them.
+func (s *Schema) ApplyDDL(src string) error {
+	src = commentPattern.ReplaceAllString(src, "")
+
+	for _, stmt := range split(src, ';') {
+		stmt = strings.TrimSpace(stmt)
+		if loc := createTablePattern.FindStringSubmatchIndex(stmt); loc != nil {
+			name := tableName(stmt[loc[2]:loc[3]])
+			body, _, err := parenthesized(stmt[loc[1]:])
+			if err != nil {
+				return fmt.Errorf("qbgen: table %s: %v", name, err)
+			}
+
+			if s.find(name) >= 0 {
+				return fmt.Errorf("qbgen: table %s is defined more than once", name)
+			}
+
+			t := Table{Name: name}
+			for _, def := range split(body, ',') {
+				def = strings.TrimSpace(def)
+				if def == "" || constraintPattern.MatchString(def) {
+					continue
+				}
+				t.Columns = append(t.Columns, parseColumn(def))
+			}
+			s.Tables = append(s.Tables, t)
+		} else if match := alterTablePattern.FindStringSubmatch(stmt); match != nil {
+			name := tableName(match[1])
+			i := s.find(name)
+			if i < 0 {
+				return fmt.Errorf("qbgen: ALTER TABLE of undefined table %s", name)
+			}
+
+			for _, action := range split(match[2], ',') {
+				if err := s.Tables[i].alter(strings.TrimSpace(action)); err != nil {
+					return fmt.Errorf("qbgen: table %s: %v", name, err)
+				}
+			}
+		} else if match := dropTablePattern.FindStringSubmatch(stmt); match != nil {
+			for _, name := range split(match[1], ',') {
+				if i := s.find(tableName(strings.TrimSpace(name))); i >= 0 {
+					s.Tables = append(s.Tables[:i:i], s.Tables[i+1:]...)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// find returns the index of the named table, or -1.
+func (s *Schema) find(name string) int {
+	for i := range s.Tables {
+		if s.Tables[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// alter applies an action of an ALTER TABLE statement to the table.
+func (t *Table) alter(action string) error {
+	if match := addColumnPattern.FindStringSubmatch(action); match != nil {
+		def := strings.TrimSpace(match[1])
+		if constraintPattern.MatchString(def) {
+			return nil
+		}
+
+		c := parseColumn(def)
+		if t.column(c.Name) >= 0 {
+			return nil
+		}
+		t.Columns = append(t.Columns, c)
+		return nil
+	}
+
+	if dropOtherPattern.MatchString(action) {
+		return nil
+	}
+
+	if match := dropColumnPattern.FindStringSubmatch(action); match != nil {
+		if i := t.column(unquote(match[1])); i >= 0 {
+			t.Columns = append(t.Columns[:i:i], t.Columns[i+1:]...)
+		}
+		return nil
+	}
+
+	if match := renameTablePattern.FindStringSubmatch(action); match != nil {
+		t.Name = tableName(match[1])
+		return nil
+	}
+
+	if match := renameColumnPattern.FindStringSubmatch(action); match != nil {
+		i := t.column(unquote(match[1]))
+		if i < 0 {
+			return fmt.Errorf("RENAME of undefined column %s", unquote(match[1]))
+		}
+		t.Columns[i].Name = unquote(match[2])
+		return nil
+	}
+
+	if match := alterTypePattern.FindStringSubmatch(action); match != nil {
+		i := t.column(unquote(match[1]))
+		if i < 0 {
+			return fmt.Errorf("ALTER of undefined column %s", unquote(match[1]))
+		}
+		t.Columns[i].Type = strings.TrimSpace(match[2])
+		return nil
+	}
+
+	if match := alterNullPattern.FindStringSubmatch(action); match != nil {
+		i := t.column(unquote(match[1]))
+		if i < 0 {
+			return fmt.Errorf("ALTER of undefined column %s", unquote(match[1]))
+		}
+		t.Columns[i].Nullable = strings.EqualFold(match[2], "DROP")
+		return nil
+	}
+
+	if alterDefaultPattern.MatchString(action) {
+		return nil
+	}
+
+	return fmt.Errorf("unsupported ALTER TABLE action %q", action)
+}
+
+// column returns the index of the named column, or -1.
+func (t *Table) column(name string) int {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseColumn reads a column definition.
+func parseColumn(def string) Column {
+	fields := strings.Fields(def)
+	typ := strings.TrimSpace(strings.TrimPrefix(def, fields[0]))
+	if loc := typeEndPattern.FindStringIndex(typ); loc != nil {
+		typ = typ[:loc[0]]
+	}
+
+	return Column{
+		Name:     unquote(fields[0]),
+		Type:     typ,
+		Nullable: !notNullPattern.MatchString(def),
+	}
+}
+
+// tableName returns the unquoted name of a table, without its schema.
+func tableName(name string) string {
+	name = unquote(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// parenthesized returns the text up to the parenthesis closing an opening
+// parenthesis that precedes s, and the text which follows it.
+func parenthesized(s string) (string, string, error) {
+	depth := 1
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth == 0 {
+				return s[:i], s[i+1:], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unbalanced parentheses")
+}
+
+// split splits s at the separators which are not parenthesized or quoted.
+func split(s string, sep rune) []string {
+	var parts []string
+	var depth, start int
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	return strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "").Replace(s)
+}
@@ -2,9 +2,10 @@ package qb
 
 import (
 	"fmt"
-	"regexp"
+	"io"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type expressionType int
@@ -30,12 +31,23 @@ const (
 	groupByExpr
 	havingExpr
 	usingExpr
+	lockingExpr
+	transactionExpr
 )
 
 type Query struct {
-	w    sqlWriter
-	last expressionType
-	str  string
+	w          sqlWriter
+	last       expressionType
+	tables     []tableRef
+	strict     bool
+	scopes     []tableScope
+	unscoped   bool
+	deleted    deletedMode
+	hardDelete bool
+	dedupArgs  bool
+	// ordered is whether the query, or the last query it combines, has an
+	// ORDER BY clause.
+	ordered bool
 	Dialect
 }
 
@@ -43,20 +55,44 @@ func (q Query) String() string {
 	return q.SQL()
 }
 
-// TODO: Support escapes.
-var placeholderPattern = regexp.MustCompile(`\?`)
+var renderPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// build returns the tokens of the query, with its scopes applied and its
+// fragments expanded, or the error which prevents it from being rendered.
+func (q Query) build() (sqlWriter, error) {
+	scoped := q.scoped()
+	if err := scoped.w.checkStrict(q.strict); err != nil {
+		return sqlWriter{}, err
+	}
 
-func (q *Query) SQL() string {
-	if q.str != "" {
-		return q.str
+	w, err := scoped.w.expand(q.Dialect)
+	if err != nil {
+		return sqlWriter{}, err
 	}
+	w.numberArgs(q.Dialect.numbered(), q.dedupArgs)
+	return w, nil
+}
 
-	sql := q.w.String()
+// render is build for the methods which panic if the query cannot be
+// rendered.
+func (q Query) render() sqlWriter {
+	w, err := q.build()
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
 
+// appendSQL appends the SQL of the rendered tokens to dst, numbering the
+// placeholders of the arguments as the dialect requires.
+func (d Dialect) appendSQL(dst []byte, w *sqlWriter) []byte {
 	var prefix string
-	switch q.Dialect {
-	case DialectDefault:
-		return sql
+	switch d {
+	case DialectDefault, DialectMysql, DialectSqlite:
 	case DialectPq:
 		prefix = "$"
 	case DialectGoracle:
@@ -64,28 +100,77 @@ func (q *Query) SQL() string {
 	case DialectMssql:
 		prefix = "@p"
 	default:
-		panic(fmt.Errorf("unrecognised dialect %d", q.Dialect))
+		panic(fmt.Errorf("unrecognised dialect %d", d))
 	}
 
-	i := 0
-	q.str = placeholderPattern.ReplaceAllStringFunc(
-		sql, func(match string) string {
-			i += 1
-			return prefix + strconv.Itoa(i)
-		})
+	for i, t := range w.tokens {
+		if i > 0 {
+			dst = append(dst, ' ')
+		}
+
+		if t.isArg && prefix != "" {
+			dst = append(dst, prefix...)
+			dst = strconv.AppendInt(dst, int64(t.n), 10)
+		} else {
+			dst = append(dst, t.sql...)
+		}
+	}
+	return dst
+}
+
+func (q Query) SQL() string {
+	w := q.render()
+
+	buf := renderPool.Get().(*[]byte)
+	*buf = q.Dialect.appendSQL((*buf)[:0], &w)
+	sql := string(*buf)
+	renderPool.Put(buf)
+	return sql
+}
+
+// Appends the SQL of the query to dst and returns the extended buffer, for
+// callers which write queries into a buffer of their own.
+func (q Query) AppendSQL(dst []byte) []byte {
+	w := q.render()
+	return q.Dialect.appendSQL(dst, &w)
+}
+
+// Writes the SQL of the query to wr. Together with Args, this streams queries
+// into batch protocols without building a string for each of them. It returns
+// the error if the query cannot be rendered.
+func (q Query) WriteTo(wr io.Writer) (int64, error) {
+	w, err := q.build()
+	if err != nil {
+		return 0, err
+	}
 
-	return q.str
+	buf := renderPool.Get().(*[]byte)
+	*buf = q.Dialect.appendSQL((*buf)[:0], &w)
+	n, err := wr.Write(*buf)
+	renderPool.Put(buf)
+	return int64(n), err
 }
 
-func (q *Query) Args() []interface{} {
-	in := q.w.Args()
-	args := make([]interface{}, len(in))
-	copy(args, in)
-	return args
+func (q Query) Args() []interface{} {
+	w := q.render()
+	return w.Args()
 }
 
 func (q Query) Build() (string, []interface{}) {
-	return q.SQL(), q.Args()
+	w := q.render()
+	return string(q.Dialect.appendSQL(nil, &w)), w.args()
+}
+
+// Returns the SQL and the arguments of the query like Build, or the error if
+// the query cannot be rendered, such as a strict query with untrusted SQL or a
+// clause which the dialect does not support, with which Build, SQL and Args
+// panic.
+func (q Query) TryBuild() (string, []interface{}, error) {
+	w, err := q.build()
+	if err != nil {
+		return "", nil, err
+	}
+	return string(q.Dialect.appendSQL(nil, &w)), w.args(), nil
 }
 
 func DialectOption(d Dialect) Query {
@@ -108,9 +193,8 @@ func (q Query) With(name string, query Query) Query {
 	}
 
 	q.last = withExpr
-	q.w.WriteSQL(prefix, name, "AS", "(")
-	q.w.Append(&query.w)
-	q.w.WriteSQL(")")
+	q.w.WriteSQL(prefix, name, "AS")
+	q.w.WriteFragment(nestedQuery{q: query, parens: true})
 	return q
 }
 
@@ -131,7 +215,7 @@ func (q Query) Select(columns ...string) Query {
 			q.w.WriteSQL(",")
 		}
 
-		q.w.WriteSQL(column)
+		q.w.writeChecked(checkColumn, column)
 	}
 	return q
 }
@@ -154,8 +238,8 @@ func (q Query) SelectColumn(expr string, args ...interface{}) Query {
 
 func (q Query) From(expr string) Query {
 	q.last = fromExpr
-	q.w.WriteSQL("FROM", expr)
-	return q
+	q.w.WriteSQL("FROM")
+	return q.writeTable(expr)
 }
 
 func (q Query) FromAs(table, alias string) Query {
@@ -169,9 +253,7 @@ func (q Query) FromSubquery(sq Query) Query {
 }
 
 func (q Query) Subquery(sq Query) Query {
-	q.w.WriteSQL("(")
-	q.w.Append(&sq.w)
-	q.w.WriteSQL(")")
+	q.w.WriteFragment(nestedQuery{q: sq, parens: true})
 	return q
 }
 
@@ -185,7 +267,8 @@ func InsertInto(expr string, columns ...string) Query {
 
 func (q Query) InsertInto(expr string, columns ...string) Query {
 	q.last = insertIntoExpr
-	q.w.WriteSQL("INSERT INTO", expr)
+	q.w.WriteSQL("INSERT INTO")
+	q = q.writeTable(expr)
 
 	if len(columns) > 0 {
 		for i, column := range columns {
@@ -196,7 +279,8 @@ func (q Query) InsertInto(expr string, columns ...string) Query {
 				start = ","
 			}
 
-			q.w.WriteSQL(start, column)
+			q.w.WriteSQL(start)
+			q.w.writeChecked(checkName, column)
 		}
 		q.w.WriteSQL(")")
 	}
@@ -214,8 +298,8 @@ func DeleteFromAs(table, alias string) Query {
 
 func (q Query) DeleteFrom(table string) Query {
 	q.last = deleteFromExpr
-	q.w.WriteSQL("DELETE FROM", table)
-	return q
+	q.w.WriteSQL("DELETE FROM")
+	return q.writeTable(table)
 }
 
 func (q Query) DeleteFromAs(table, alias string) Query {
@@ -246,16 +330,7 @@ func (q Query) ValueTuples(tuples ...[]interface{}) Query {
 				q.w.WriteSQL(",")
 			}
 
-			switch x := v.(type) {
-			case literal:
-				q.w.WriteSQL(x.String())
-			case Query:
-				q.w.WriteSQL("(")
-				q.w.Append(&x.w)
-				q.w.WriteSQL(")")
-			default:
-				q.w.WriteArg(x)
-			}
+			q.w.WriteValue(v)
 		}
 
 		q.w.WriteSQL(")")
@@ -293,7 +368,7 @@ func (q Query) Where(pred Predicate) Query {
 	}
 
 	q.last = whereExpr
-	q.w.Append(&pred.w)
+	q.w.WriteFragment(condition{pred})
 	return q
 }
 
@@ -305,21 +380,30 @@ func (q Query) Returning(columns ...string) Query {
 			q.w.WriteSQL(",")
 		}
 
-		q.w.WriteSQL(column)
+		q.w.writeChecked(checkColumn, column)
 	}
 	return q
 }
 
 func (q Query) OrderBy(first string, rest ...string) Query {
 	q.last = orderByExpr
-	q.w.WriteSQL("ORDER BY", first)
+	q.ordered = true
+	q.w.WriteSQL("ORDER BY")
+	q.w.write(orderTerm(first))
 	for _, column := range rest {
-		q.w.WriteSQL(",", column)
+		q.w.WriteSQL(",")
+		q.w.write(orderTerm(column))
 	}
 	return q
 }
 
 func (q Query) appending(t expressionType, expr string, args ...interface{}) Query {
+	if t == combiningQuery {
+		q.ordered = false
+	} else if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(expr)), "ORDER BY") {
+		q.ordered = true
+	}
+
 	q.last = t
 	q.w.WriteExpr(expr, args...)
 	return q
@@ -339,8 +423,8 @@ func Update(table string) Query {
 
 func (q Query) Update(table string) Query {
 	q.last = updateExpr
-	q.w.WriteSQL("UPDATE", table)
-	return q
+	q.w.WriteSQL("UPDATE")
+	return q.writeTable(table)
 }
 
 func (q Query) Set(expr string, args ...interface{}) Query {
@@ -357,7 +441,16 @@ func (q Query) Set(expr string, args ...interface{}) Query {
 
 func (q Query) SetValues(values Values) Query {
 	for k, v := range values {
-		q = q.Set(k+` = ?`, v)
+		prefix := "SET"
+		if q.last == setExpr {
+			prefix = ","
+		}
+
+		q.last = setExpr
+		q.w.WriteSQL(prefix)
+		q.w.writeChecked(checkName, k)
+		q.w.WriteSQL("=")
+		q.w.WriteValue(v)
 	}
 	return q
 }
@@ -392,33 +485,25 @@ func (q Query) ExceptAll() Query {
 	return q.appending(combiningQuery, "EXCEPT ALL")
 }
 
-func (q Query) Limit(limit int64) Query {
-	return q.appending(limitExpr, "LIMIT "+strconv.FormatInt(limit, 10))
-}
-
-func (q Query) LimitAll() Query {
-	return q.appending(limitExpr, "LIMIT ALL")
-}
-
-func (q Query) Offset(offset int64) Query {
-	return q.appending(offsetExpr, "OFFSET "+strconv.FormatInt(offset, 10))
-}
-
 func (q Query) joinOn(joinType string, table string, predicate Predicate) Query {
 	q.last = joinExpr
-	q.w.WriteSQL(joinType + " " + table + " ON")
-	q.w.Append(&predicate.w)
+	q.w.WriteSQL(joinType)
+	q = q.writeTable(table)
+	q.w.WriteSQL("ON")
+	q.w.WriteFragment(condition{predicate})
 	return q
 }
 
 func (q Query) joinUsing(joinType string, table string, columns ...string) Query {
 	q.last = joinExpr
-	q.w.WriteSQL(joinType + " " + table + " USING (")
+	q.w.WriteSQL(joinType)
+	q = q.writeTable(table)
+	q.w.WriteSQL("USING (")
 	for i, column := range columns {
 		if i > 0 {
 			q.w.WriteSQL(",")
 		}
-		q.w.WriteSQL(column)
+		q.w.writeChecked(checkColumn, column)
 	}
 	q.w.WriteSQL(")")
 	return q
@@ -488,91 +573,76 @@ func (q Query) FullJoinAsUsing(table, alias string, columns ...string) Query {
 	return q.joinUsing("FULL JOIN", As(table, alias), columns...)
 }
 
+func (q Query) naturalJoin(joinType string, table string) Query {
+	q.last = joinExpr
+	q.w.WriteSQL(joinType)
+	return q.writeTable(table)
+}
+
 func (q Query) NaturalJoin(table string) Query {
-	return q.appending(joinExpr, "NATURAL JOIN "+table)
+	return q.naturalJoin("NATURAL JOIN", table)
 }
 
 func (q Query) NaturalJoinAs(table, alias string) Query {
-	return q.appending(joinExpr, "NATURAL JOIN "+As(table, alias))
+	return q.naturalJoin("NATURAL JOIN", As(table, alias))
 }
 
 func (q Query) NaturalLeftJoin(table string) Query {
-	return q.appending(joinExpr, "NATURAL LEFT JOIN "+table)
+	return q.naturalJoin("NATURAL LEFT JOIN", table)
 }
 
 func (q Query) NaturalLeftJoinAs(table, alias string) Query {
-	return q.appending(joinExpr, "NATURAL LEFT JOIN "+As(table, alias))
+	return q.naturalJoin("NATURAL LEFT JOIN", As(table, alias))
 }
 
 func (q Query) NaturalRightJoin(table string) Query {
-	return q.appending(joinExpr, "NATURAL RIGHT JOIN "+table)
+	return q.naturalJoin("NATURAL RIGHT JOIN", table)
 }
 
 func (q Query) NaturalRightJoinAs(table, alias string) Query {
-	return q.appending(joinExpr, "NATURAL RIGHT JOIN "+As(table, alias))
+	return q.naturalJoin("NATURAL RIGHT JOIN", As(table, alias))
 }
 
 // Appends a NATURAL FULL JOIN clause.
-//  ... NATURAL FULL JOIN table
+//
+//	... NATURAL FULL JOIN table
 func (q Query) NaturalFullJoin(table string) Query {
-	return q.appending(joinExpr, "NATURAL FULL JOIN "+table)
+	return q.naturalJoin("NATURAL FULL JOIN", table)
 }
 
 func (q Query) NaturalFullJoinAs(table, alias string) Query {
-	return q.appending(joinExpr, "NATURAL FULL JOIN "+As(table, alias))
+	return q.naturalJoin("NATURAL FULL JOIN", As(table, alias))
 }
 
 // Creates a query with multiple statements.
-//  qs0; [qs1; [qs2; ...]]
+//
+//	qs0; [qs1; [qs2; ...]]
 func Multiple(qs ...Query) Query {
 	var out Query
 	for _, in := range qs {
-		out.w.Append(&in.w)
+		out.w.WriteFragment(nestedQuery{q: in})
 		out.w.WriteSQL(";")
 	}
 	return out
 }
 
 // Appends a GROUP BY clause.
-//  ... GROUP BY field0[, field1[, ...]].
+//
+//	... GROUP BY field0[, field1[, ...]].
 func (q Query) GroupBy(fields ...string) Query {
-	return q.appending(groupByExpr, "GROUP BY "+strings.Join(fields, ", "))
+	q.last = groupByExpr
+	q.w.WriteSQL("GROUP BY")
+	q.w.writeChecked(checkColumns, strings.Join(fields, ", "))
+	return q
 }
 
 // Appends a HAVING clause. This should follow a GROUP BY clause.
-//  ... HAVING predicate
+//
+//	... HAVING predicate
 func (q Query) Having(predicate Predicate) Query {
 	q.last = havingExpr
 	q.w.WriteSQL("HAVING")
-	q.w.Append(&predicate.w)
-	return q
-}
-
-func (q Query) ForUpdate() Query {
-	q.w.WriteSQL("FOR UPDATE")
-	return q
-}
-
-func (q Query) ForShare() Query {
-	q.w.WriteSQL("FOR SHARE")
-	return q
-}
-
-func Begin() Query {
-	return Query{}.Begin()
-}
-
-func (q Query) Begin() Query {
-	q.w.WriteSQL("BEGIN")
-	return q
-}
-
-func Commit() Query {
-	return Query{}.Commit()
-}
-
-func (q Query) Commit() Query {
-	q.w.WriteSQL("COMMIT")
+	q.w.WriteFragment(condition{predicate})
 	return q
 }
 
@@ -587,8 +657,8 @@ func (q Query) Map(f func(q Query) Query) Query {
 
 func (q Query) Using(table string) Query {
 	q.last = usingExpr
-	q.w.WriteSQL("USING", table)
-	return q
+	q.w.WriteSQL("USING")
+	return q.writeTable(table)
 }
 
 func (q Query) UsingAs(table, alias string) Query {
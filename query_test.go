@@ -32,7 +32,7 @@ func ExampleQuery_Having() {
 	// Output: SELECT * FROM t1 GROUP BY a, b HAVING a < ?
 }
 
-func ExampleQuery_FunctionalMap() {
+func ExampleQuery_Map() {
 	var id int64
 	joined := true
 
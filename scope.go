@@ -0,0 +1,132 @@
+package qb
+
+import (
+	"sync"
+)
+
+// A Scope returns the predicate which restricts the rows of a table that a
+// query may see, for a reference to the table. It can qualify its columns
+// with the name of the reference:
+//  func(ref qb.TableRef) qb.Predicate {
+//  	return qb.Pred(ref.Name()+".deleted_at IS NULL")
+//  }
+type Scope func(ref TableRef) Predicate
+
+type tableScope struct {
+	table string
+	scope Scope
+}
+
+var registeredScopes struct {
+	sync.RWMutex
+	scopes      []tableScope
+	softDeletes []softDelete
+}
+
+// Registers the scope of a table. When a query is rendered, the predicate of
+// the scope is added to every SELECT, UPDATE and DELETE statement which
+// references the table, including in joins, subqueries and common table
+// expressions, unless the query is Unscoped. This replaces the scope already
+// registered for the table, if any.
+func RegisterScope(table string, s Scope) {
+	registeredScopes.Lock()
+	defer registeredScopes.Unlock()
+
+	scopes1 := make([]tableScope, 0, len(registeredScopes.scopes)+1)
+	for _, ts := range registeredScopes.scopes {
+		if ts.table != table {
+			scopes1 = append(scopes1, ts)
+		}
+	}
+	registeredScopes.scopes = append(scopes1, tableScope{table, s})
+}
+
+// Removes the scope registered for a table.
+func UnregisterScope(table string) {
+	registeredScopes.Lock()
+	defer registeredScopes.Unlock()
+
+	scopes1 := make([]tableScope, 0, len(registeredScopes.scopes))
+	for _, ts := range registeredScopes.scopes {
+		if ts.table != table {
+			scopes1 = append(scopes1, ts)
+		}
+	}
+	registeredScopes.scopes = scopes1
+}
+
+func WithScope(table string, s Scope) Query {
+	return Query{}.WithScope(table, s)
+}
+
+// Adds a scope for a table to the query, in addition to the registered
+// scopes. The scope also applies to the queries nested in the query. This is
+// useful for scopes which depend on the request, such as a tenant filter:
+//  q := qb.WithScope("orders", func(ref qb.TableRef) qb.Predicate {
+//  	return qb.Pred(ref.Name()+".tenant_id = ?", tenantID)
+//  })
+func (q Query) WithScope(table string, s Scope) Query {
+	scopes1 := make([]tableScope, 0, len(q.scopes)+1)
+	scopes1 = append(scopes1, q.scopes...)
+	q.scopes = append(scopes1, tableScope{table, s})
+	return q
+}
+
+// Renders the query without the predicates of any scope, including in the
+// queries nested in it. This does not affect soft deletes; see WithDeleted
+// and HardDelete.
+func (q Query) Unscoped() Query {
+	q.unscoped = true
+	return q
+}
+
+// scoping is the state with which the scopes of a query and the queries
+// nested in it are applied.
+type scoping struct {
+	scopes      []tableScope
+	softDeletes []softDelete
+	deleted     deletedMode
+	hardDelete  bool
+	unscoped    bool
+}
+
+// scoped returns the query with the predicates of the registered scopes and
+// the scopes of the query added, and its soft deletes rewritten.
+func (q Query) scoped() Query {
+	registeredScopes.RLock()
+	s := scoping{
+		scopes:      registeredScopes.scopes,
+		softDeletes: registeredScopes.softDeletes,
+	}
+	registeredScopes.RUnlock()
+
+	return q.applyScopes(s)
+}
+
+func (q Query) applyScopes(s scoping) Query {
+	s.unscoped = s.unscoped || q.unscoped
+	s.hardDelete = s.hardDelete || q.hardDelete
+	if q.deleted != deletedDefault {
+		s.deleted = q.deleted
+	}
+
+	if len(q.scopes) > 0 {
+		scopes1 := make([]tableScope, 0, len(s.scopes)+len(q.scopes))
+		scopes1 = append(scopes1, s.scopes...)
+		s.scopes = append(scopes1, q.scopes...)
+	}
+
+	q.w = q.w.mapQueries(func(sq Query) Query {
+		return sq.applyScopes(s)
+	})
+
+	q = q.applySoftDeletes(s)
+	if s.unscoped {
+		return q
+	}
+
+	for _, ts := range s.scopes {
+		q = q.filterTable(ts.table, ts.scope)
+	}
+	return q
+}
@@ -0,0 +1,147 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestScope(t *testing.T) {
+	qb.RegisterScope("orders", func(ref qb.TableRef) qb.Predicate {
+		return qb.Pred(ref.Name() + ".deleted_at IS NULL")
+	})
+	defer qb.UnregisterScope("orders")
+
+	tenant := func(ref qb.TableRef) qb.Predicate {
+		return qb.Pred(ref.Name()+".tenant_id = ?", 7)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		args  []interface{}
+		query func() qb.Query
+	}{
+		{
+			name: "registered",
+			expr: `SELECT * FROM orders WHERE ( id = ? ) AND ( orders.deleted_at IS NULL )`,
+			args: []interface{}{1},
+			query: func() qb.Query {
+				return qb.Select("*").From("orders").Where(qb.Pred("id = ?", 1))
+			},
+		},
+		{
+			name: "query scope on joins",
+			expr: `SELECT * FROM users u JOIN orders o ON ( o.user_id = u.id ) AND ( o.deleted_at IS NULL ) AND ( o.tenant_id = $1 ) WHERE u.tenant_id = $2`,
+			args: []interface{}{7, 7},
+			query: func() qb.Query {
+				return qb.WithDialectPQ().
+					WithScope("users", tenant).
+					WithScope("orders", tenant).
+					Select("*").From("users u").
+					JoinOn("orders o", qb.Pred("o.user_id = u.id"))
+			},
+		},
+		{
+			name: "right and full joins",
+			expr: `SELECT * FROM users u RIGHT JOIN orders o ON o.user_id = u.id FULL JOIN orders p ON p.id = o.parent_id WHERE ( o.deleted_at IS NULL ) AND ( p.deleted_at IS NULL ) AND ( o.tenant_id = ? ) AND ( p.tenant_id = ? )`,
+			args: []interface{}{7, 7},
+			query: func() qb.Query {
+				return qb.WithScope("orders", tenant).
+					Select("*").From("users u").
+					RightJoinOn("orders o", qb.Pred("o.user_id = u.id")).
+					FullJoinOn("orders p", qb.Pred("p.id = o.parent_id"))
+			},
+		},
+		{
+			name: "subquery",
+			expr: `SELECT count(*) FROM ( SELECT * FROM orders WHERE ( orders.deleted_at IS NULL ) AND ( orders.tenant_id = ? ) ) AS "t"`,
+			args: []interface{}{7},
+			query: func() qb.Query {
+				return qb.WithScope("orders", tenant).
+					Select("count(*)").
+					FromSubquery(qb.Select("*").From("orders")).
+					As("t")
+			},
+		},
+		{
+			name: "raw or",
+			expr: `SELECT * FROM orders WHERE ( public OR tenant_id = ? ) AND ( orders.deleted_at IS NULL ) AND ( orders.tenant_id = ? )`,
+			args: []interface{}{8, 7},
+			query: func() qb.Query {
+				return qb.WithScope("orders", tenant).
+					Select("*").From("orders").
+					Where(qb.Pred("public OR tenant_id = ?", 8))
+			},
+		},
+		{
+			name: "appended where in subquery",
+			expr: `SELECT * FROM users WHERE id IN ( SELECT user_id FROM orders WHERE ( a = 1 OR b = 2 ) AND ( orders.deleted_at IS NULL ) )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("*").From("users").
+					Where(qb.Pred("id IN ?", qb.Select("user_id").From("orders").Append("WHERE a = 1 OR b = 2")))
+			},
+		},
+		{
+			name: "comma join",
+			expr: `SELECT * FROM users , orders WHERE ( users.id = orders.user_id ) AND ( orders.deleted_at IS NULL )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("*").From("users, orders").Where(qb.Pred("users.id = orders.user_id"))
+			},
+		},
+		{
+			name: "update and delete",
+			expr: `UPDATE orders SET a = ? WHERE orders.deleted_at IS NULL ; DELETE FROM orders WHERE orders.deleted_at IS NULL ;`,
+			args: []interface{}{1},
+			query: func() qb.Query {
+				return qb.Multiple(
+					qb.Update("orders").Set("a = ?", 1),
+					qb.DeleteFrom("orders"))
+			},
+		},
+		{
+			name: "insert",
+			expr: `INSERT INTO orders ( a ) VALUES ( ? )`,
+			args: []interface{}{1},
+			query: func() qb.Query {
+				return qb.InsertInto("orders", "a").Values(1)
+			},
+		},
+		{
+			name: "unscoped",
+			expr: `SELECT * FROM orders WHERE id IN ( SELECT order_id FROM orders )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.WithScope("orders", tenant).
+					Select("*").From("orders").
+					Where(qb.Pred("id IN ?", qb.Select("order_id").From("orders"))).
+					Unscoped()
+			},
+		},
+		{
+			name: "unscoped subquery",
+			expr: `SELECT * FROM orders WHERE ( id IN ( SELECT order_id FROM orders ) ) AND ( orders.deleted_at IS NULL )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("*").From("orders").
+					Where(qb.Pred("id IN ?", qb.Select("order_id").From("orders").Unscoped()))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query()
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+
+	qb.UnregisterScope("orders")
+	q := qb.Select("*").From("orders")
+	require.Equal(t, `SELECT * FROM orders`, q.SQL())
+}
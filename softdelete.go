@@ -0,0 +1,137 @@
+package qb
+
+// softDelete records the column in which a table marks its deleted rows.
+type softDelete struct {
+	table  string
+	column string
+}
+
+type deletedMode int
+
+const (
+	deletedDefault deletedMode = iota
+	withDeleted
+	onlyDeleted
+)
+
+// Registers a table whose rows are soft-deleted by setting the given column,
+// e.g. deleted_at, to the current time. When a query is rendered:
+//  DELETE FROM table ...
+// becomes
+//  UPDATE table SET column = now() ... AND table.column IS NULL
+// and every reference to the table in a SELECT statement, or in the FROM and
+// USING clauses of other statements, is given the predicate:
+//  table.column IS NULL
+// See WithDeleted, OnlyDeleted and HardDelete.
+func RegisterSoftDelete(table, column string) {
+	registeredScopes.Lock()
+	defer registeredScopes.Unlock()
+
+	softDeletes1 := make([]softDelete, 0, len(registeredScopes.softDeletes)+1)
+	for _, sd := range registeredScopes.softDeletes {
+		if sd.table != table {
+			softDeletes1 = append(softDeletes1, sd)
+		}
+	}
+	registeredScopes.softDeletes = append(softDeletes1, softDelete{table, column})
+}
+
+// Removes the soft delete registered for a table.
+func UnregisterSoftDelete(table string) {
+	registeredScopes.Lock()
+	defer registeredScopes.Unlock()
+
+	softDeletes1 := make([]softDelete, 0, len(registeredScopes.softDeletes))
+	for _, sd := range registeredScopes.softDeletes {
+		if sd.table != table {
+			softDeletes1 = append(softDeletes1, sd)
+		}
+	}
+	registeredScopes.softDeletes = softDeletes1
+}
+
+// Includes soft-deleted rows in the query and the queries nested in it.
+func (q Query) WithDeleted() Query {
+	q.deleted = withDeleted
+	return q
+}
+
+// Restricts the query and the queries nested in it to soft-deleted rows.
+//  ... WHERE table.column IS NOT NULL
+func (q Query) OnlyDeleted() Query {
+	q.deleted = onlyDeleted
+	return q
+}
+
+// Renders the DELETE statements of the query and the queries nested in it as
+// such, even for soft-deleted tables.
+func (q Query) HardDelete() Query {
+	q.hardDelete = true
+	return q
+}
+
+// currentTimestamp is the current time in the SQL of each dialect.
+type currentTimestamp struct{}
+
+func (currentTimestamp) writeTo(w *sqlWriter, d Dialect) error {
+	switch d {
+	case DialectMssql, DialectGoracle, DialectSqlite:
+		w.WriteSQL("CURRENT_TIMESTAMP")
+	default:
+		w.WriteSQL("now()")
+	}
+	return nil
+}
+
+// applySoftDeletes rewrites the soft deletes of the query, and filters the
+// soft-deleted rows of the tables it reads, but not those of the queries
+// nested in it.
+func (q Query) applySoftDeletes(s scoping) Query {
+	for _, sd := range s.softDeletes {
+		for i := 0; i < len(q.tables); i++ {
+			ref := q.tables[i]
+			if !matchesTable(ref.public().Table, sd.table) {
+				continue
+			}
+
+			column := ref.public().Name() + "." + sd.column
+			switch ref.t {
+			case deleteFromExpr:
+				if s.hardDelete || ref.at == 0 || q.w.tokens[ref.at-1].sql != "DELETE FROM" {
+					continue
+				}
+
+				q.w.replaceSQL(ref.at-1, "UPDATE")
+				q = q.insert(ref.at+1,
+					token{sql: "SET"},
+					token{sql: sd.column},
+					token{sql: "="},
+					token{frag: currentTimestamp{}})
+
+				q = q.rewriteUsing(ref.at)
+				q = q.addCondition(ref.at, Pred(column+" IS NULL"))
+
+			case fromExpr, joinExpr, usingExpr:
+				switch s.deleted {
+				case deletedDefault:
+					q = q.addCondition(ref.at, Pred(column+" IS NULL"))
+				case onlyDeleted:
+					q = q.addCondition(ref.at, Pred(column+" IS NOT NULL"))
+				}
+			}
+		}
+	}
+	return q
+}
+
+// rewriteUsing rewrites the USING clause of a DELETE statement which follows
+// the table at the given position as the FROM clause of an UPDATE statement.
+func (q Query) rewriteUsing(at int) Query {
+	for _, ref := range q.tables {
+		if ref.t == usingExpr && ref.at > at && q.w.tokens[ref.at-1].sql == "USING" {
+			q.w.replaceSQL(ref.at-1, "FROM")
+			return q
+		}
+	}
+	return q
+}
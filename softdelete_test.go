@@ -0,0 +1,192 @@
+package qb_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"github.com/tetratom/qb"
+)
+
+func TestSoftDelete(t *testing.T) {
+	qb.RegisterSoftDelete("orders", "deleted_at")
+	defer qb.UnregisterSoftDelete("orders")
+
+	tests := []struct {
+		name  string
+		expr  string
+		args  []interface{}
+		query func() qb.Query
+	}{
+		{
+			name: "delete",
+			expr: `UPDATE orders SET deleted_at = now() WHERE ( id = $1 ) AND ( orders.deleted_at IS NULL ) RETURNING id`,
+			args: []interface{}{1},
+			query: func() qb.Query {
+				return qb.WithDialectPQ().DeleteFrom("orders").Where(qb.Pred("id = ?", 1)).Returning("id")
+			},
+		},
+		{
+			name: "delete using",
+			expr: `UPDATE orders AS "o" SET deleted_at = now() FROM users WHERE ( o.user_id = users.id ) AND ( o.deleted_at IS NULL )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.DeleteFromAs("orders", "o").Using("users").Where(qb.Pred("o.user_id = users.id"))
+			},
+		},
+		{
+			name: "delete mssql",
+			expr: `UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE orders.deleted_at IS NULL`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.WithDialectMssql().DeleteFrom("orders")
+			},
+		},
+		{
+			name: "hard delete",
+			expr: `DELETE FROM orders WHERE id = ?`,
+			args: []interface{}{1},
+			query: func() qb.Query {
+				return qb.DeleteFrom("orders").Where(qb.Pred("id = ?", 1)).HardDelete()
+			},
+		},
+		{
+			name: "other tables",
+			expr: `DELETE FROM users`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.DeleteFrom("users")
+			},
+		},
+		{
+			name: "select",
+			expr: `SELECT * FROM users u JOIN orders o ON ( o.user_id = u.id ) AND ( o.deleted_at IS NULL ) WHERE u.id IN ( SELECT user_id FROM orders WHERE orders.deleted_at IS NULL )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("*").From("users u").
+					JoinOn("orders o", qb.Pred("o.user_id = u.id")).
+					Where(qb.Pred("u.id IN ?", qb.Select("user_id").From("orders")))
+			},
+		},
+		{
+			name: "right join",
+			expr: `SELECT * FROM users u RIGHT JOIN orders o ON o.user_id = u.id WHERE o.deleted_at IS NULL`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("*").From("users u").RightJoinOn("orders o", qb.Pred("o.user_id = u.id"))
+			},
+		},
+		{
+			name: "full join",
+			expr: `SELECT * FROM users u FULL JOIN orders o ON o.user_id = u.id WHERE ( u.active = ? ) AND ( o.deleted_at IS NULL )`,
+			args: []interface{}{true},
+			query: func() qb.Query {
+				return qb.Select("*").From("users u").FullJoinOn("orders o", qb.Pred("o.user_id = u.id")).Where(qb.Pred("u.active = ?", true))
+			},
+		},
+		{
+			name: "raw or",
+			expr: `SELECT id FROM orders WHERE ( a = 1 OR b = 2 ) AND ( orders.deleted_at IS NULL )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("id").From("orders").Where(qb.Pred("a = 1 OR b = 2"))
+			},
+		},
+		{
+			name: "with deleted",
+			expr: `SELECT * FROM orders WHERE id IN ( SELECT order_id FROM orders )`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("*").From("orders").
+					Where(qb.Pred("id IN ?", qb.Select("order_id").From("orders"))).
+					WithDeleted()
+			},
+		},
+		{
+			name: "only deleted",
+			expr: `SELECT * FROM orders WHERE orders.deleted_at IS NOT NULL ORDER BY id`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.Select("*").From("orders").OrderBy("id").OnlyDeleted()
+			},
+		},
+		{
+			name: "unscoped",
+			expr: `UPDATE orders SET deleted_at = now() WHERE orders.deleted_at IS NULL`,
+			args: []interface{}{},
+			query: func() qb.Query {
+				return qb.DeleteFrom("orders").Unscoped()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query()
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+}
+
+func TestSoftDelete_rawOr(t *testing.T) {
+	qb.RegisterSoftDelete("orders", "deleted_at")
+	defer qb.UnregisterSoftDelete("orders")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE orders (id INTEGER, a INTEGER, b INTEGER, deleted_at TEXT);
+		INSERT INTO orders VALUES (1, 1, 0, NULL), (2, 1, 0, 'yesterday'), (3, 0, 2, 'yesterday'), (4, 0, 2, NULL)`)
+	require.NoError(t, err)
+
+	q := qb.Select("id").From("orders").Where(qb.Pred("a = 1 OR b = 2")).OrderBy("id")
+	rows, err := db.Query(q.SQL(), q.Args()...)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []int{1, 4}, ids)
+}
+
+func TestSoftDelete_rightJoin(t *testing.T) {
+	qb.RegisterSoftDelete("orders", "deleted_at")
+	defer qb.UnregisterSoftDelete("orders")
+
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER);
+		CREATE TABLE orders (id INTEGER, user_id INTEGER, deleted_at TEXT);
+		INSERT INTO users VALUES (1);
+		INSERT INTO orders VALUES (1, 1, NULL), (2, 1, 'yesterday'), (3, 2, NULL), (4, 2, 'yesterday')`)
+	require.NoError(t, err)
+
+	q := qb.Select("o.id").From("users u").RightJoinOn("orders o", qb.Pred("o.user_id = u.id")).OrderBy("o.id")
+	rows, err := db.Query(q.SQL(), q.Args()...)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []int{1, 3}, ids)
+}
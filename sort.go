@@ -0,0 +1,136 @@
+package qb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Parses a comma separated list of sort fields, such as the sort parameter of
+// a request, into ORDER BY terms. The allowed map gives the column or
+// expression to sort by for each field. A field is optionally followed by its
+// direction and the position of NULL values, or preceded by a minus sign for a
+// descending sort:
+//  -created_at,name asc nulls last
+// returns, with allowed fields mapped to u.created_at and u.name,
+//  []string{"u.created_at DESC", "u.name ASC NULLS LAST"}
+// which can be passed to OrderBy. It is an error to sort by a field which is
+// not allowed, or by the same field twice. An input without fields, such as an
+// empty sort parameter, returns no terms and no error; OrderBy takes at least
+// one term, so callers check for them first:
+//  if len(terms) > 0 {
+//  	q = q.OrderBy(terms[0], terms[1:]...)
+//  }
+// Strict queries only accept the
+// terms of columns; the terms of expressions such as lower(u.email), which are
+// as trusted as the allowed map, are passed to OrderByExpr with Raw instead:
+//  q = q.OrderByExpr("?", qb.Raw(term))
+func ParseSort(input string, allowed map[string]string) ([]string, error) {
+	var terms []string
+	seen := map[string]bool{}
+	for _, field := range strings.Split(input, ",") {
+		words := strings.Fields(field)
+		if len(words) == 0 {
+			continue
+		}
+
+		name, dir := words[0], ""
+		switch {
+		case strings.HasPrefix(name, "-"):
+			name, dir = name[1:], "DESC"
+		case strings.HasPrefix(name, "+"):
+			name, dir = name[1:], "ASC"
+		}
+		words = words[1:]
+
+		if len(words) > 0 && dir == "" {
+			switch strings.ToUpper(words[0]) {
+			case "ASC", "DESC":
+				dir = strings.ToUpper(words[0])
+				words = words[1:]
+			case "NULLS":
+			default:
+				return nil, fmt.Errorf("qb: invalid sort direction %q", words[0])
+			}
+		}
+		if dir == "" {
+			dir = "ASC"
+		}
+
+		nulls := ""
+		if len(words) > 0 {
+			if len(words) != 2 || strings.ToUpper(words[0]) != "NULLS" {
+				return nil, fmt.Errorf("qb: invalid sort term %q", strings.TrimSpace(field))
+			}
+			nulls = strings.ToUpper(words[1])
+			if nulls != "FIRST" && nulls != "LAST" {
+				return nil, fmt.Errorf("qb: invalid sort nulls position %q", words[1])
+			}
+		}
+
+		column, ok := allowed[name]
+		if !ok {
+			return nil, fmt.Errorf("qb: cannot sort by %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("qb: cannot sort by %q twice", name)
+		}
+		seen[name] = true
+
+		term := column + " " + dir
+		if nulls != "" {
+			term += " NULLS " + nulls
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+var nullsOrderPattern = regexp.MustCompile(`(?is)^\s*(.+?)(?:\s+(ASC|DESC))?\s+NULLS\s+(FIRST|LAST)\s*$`)
+
+// nullsOrder is an ORDER BY term with the position of NULL values, which is
+// emulated in the dialects without NULLS FIRST and NULLS LAST.
+type nullsOrder struct {
+	sql    string
+	column string
+	dir    string
+	nulls  string
+}
+
+// orderTerm returns the token of an ORDER BY term.
+func orderTerm(sql string) token {
+	m := nullsOrderPattern.FindStringSubmatch(sql)
+	if m == nil {
+		return token{sql: sql, check: checkOrder}
+	}
+
+	dir := strings.ToUpper(m[2])
+	if dir == "" {
+		dir = "ASC"
+	}
+	return token{frag: nullsOrder{sql: sql, column: m[1], dir: dir, nulls: strings.ToUpper(m[3])}}
+}
+
+func (o nullsOrder) writeTo(w *sqlWriter, d Dialect) error {
+	var isNull string
+	switch d {
+	case DialectMysql:
+		isNull = o.column + " IS NULL"
+	case DialectMssql:
+		isNull = "CASE WHEN " + o.column + " IS NULL THEN 1 ELSE 0 END"
+	default:
+		w.WriteSQL(o.column + " " + o.dir + " NULLS " + o.nulls)
+		return nil
+	}
+
+	// NULL values sort first in ascending order in mysql and mssql.
+	if (o.dir == "ASC") != (o.nulls == "FIRST") {
+		if o.nulls == "FIRST" {
+			isNull += " DESC"
+		}
+		w.WriteSQL(isNull)
+		w.WriteSQL(",")
+	}
+	w.WriteSQL(o.column + " " + o.dir)
+	return nil
+}
@@ -0,0 +1,90 @@
+package qb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestParseSort(t *testing.T) {
+	allowed := map[string]string{
+		"name":       "u.name",
+		"created_at": "u.created_at",
+		"email":      "lower(u.email)",
+	}
+
+	tests := []struct {
+		input string
+		terms []string
+		err   string
+	}{
+		{input: "", terms: nil},
+		{input: " , ", terms: nil},
+		{input: "-created_at,name", terms: []string{"u.created_at DESC", "u.name ASC"}},
+		{input: "name DESC, +created_at", terms: []string{"u.name DESC", "u.created_at ASC"}},
+		{input: "name asc nulls last", terms: []string{"u.name ASC NULLS LAST"}},
+		{input: "-name nulls first", terms: []string{"u.name DESC NULLS FIRST"}},
+		{input: "name nulls first", terms: []string{"u.name ASC NULLS FIRST"}},
+		{input: "-email", terms: []string{"lower(u.email) DESC"}},
+		{input: "password", err: `qb: cannot sort by "password"`},
+		{input: "name,-name", err: `qb: cannot sort by "name" twice`},
+		{input: "name up", err: `qb: invalid sort direction "up"`},
+		{input: "name nulls", err: `qb: invalid sort term "name nulls"`},
+		{input: "-name asc", err: `qb: invalid sort term "-name asc"`},
+		{input: "name nulls middle", err: `qb: invalid sort nulls position "middle"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			terms, err := qb.ParseSort(tt.input, allowed)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.terms, terms)
+		})
+	}
+}
+
+func TestQuery_OrderBy_Nulls(t *testing.T) {
+	terms, err := qb.ParseSort("-created_at nulls last,name nulls last", map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		dialect qb.Dialect
+		expr    string
+	}{
+		{qb.DialectDefault, `SELECT * FROM users ORDER BY created_at DESC NULLS LAST , name ASC NULLS LAST`},
+		{qb.DialectPq, `SELECT * FROM users ORDER BY created_at DESC NULLS LAST , name ASC NULLS LAST`},
+		{qb.DialectSqlite, `SELECT * FROM users ORDER BY created_at DESC NULLS LAST , name ASC NULLS LAST`},
+		{qb.DialectMysql, `SELECT * FROM users ORDER BY created_at DESC , name IS NULL , name ASC`},
+		{qb.DialectMssql, `SELECT * FROM users ORDER BY created_at DESC , CASE WHEN name IS NULL THEN 1 ELSE 0 END , name ASC`},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.dialect), func(t *testing.T) {
+			q := qb.Select("*").From("users").OrderBy(terms[0], terms[1:]...).DialectOption(tt.dialect)
+			require.Equal(t, tt.expr, q.SQL())
+		})
+	}
+
+	q := qb.WithDialectMysql().Select("*").From("users").OrderBy("a DESC NULLS FIRST", "b NULLS FIRST")
+	require.Equal(t, `SELECT * FROM users ORDER BY a IS NULL DESC , a DESC , b ASC`, q.SQL())
+
+	q = qb.Strict().Select("*").From("users").OrderBy("a; DROP TABLE users NULLS LAST")
+	require.Panics(t, func() { q.SQL() })
+
+	terms, err = qb.ParseSort("-email", map[string]string{"email": "lower(email)"})
+	require.NoError(t, err)
+	q = qb.Strict().Select("*").From("users").OrderBy(terms[0])
+	require.Panics(t, func() { q.SQL() })
+	q = qb.Strict().Select("*").From("users").OrderByExpr("?", qb.Raw(terms[0]))
+	require.Equal(t, `SELECT * FROM users ORDER BY  lower(email) DESC`, q.SQL())
+}
@@ -0,0 +1,191 @@
+package qb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RawSQL is SQL which is trusted, and which strict queries accept as-is. It is
+// a distinct type, so that no string, such as one read from a request, is
+// taken for raw SQL.
+type RawSQL struct {
+	sql string
+}
+
+// Marks SQL as trusted. Raw SQL is passed as an argument wherever
+// expressions take arguments, such as to SelectColumn, Pred and OrderByExpr,
+// and is written as SQL:
+//  qb.Strict().SelectColumn("?", qb.Raw("count(*)")).From("users")
+func Raw(sql string) RawSQL {
+	return RawSQL{sql: sql}
+}
+
+func (r RawSQL) String() string {
+	return r.sql
+}
+
+func Strict() Query {
+	return Query{}.Strict()
+}
+
+// Puts the query into strict mode. When a strict query is rendered, the table
+// names, columns, ORDER BY and GROUP BY terms written by the builder methods,
+// including the columns of InsertInto, SetValues and Returning, must be
+// identifiers, and literals may not be written with Lit; otherwise SQL() and
+// Args() panic. SQL which is trusted is passed as an argument with Raw
+// instead. The queries nested in a strict query are checked as well.
+// Identifiers may be qualified, quoted and aliased:
+//  schema.table AS "alias"
+//  t.column
+//  t.*
+//  column DESC NULLS LAST
+// Expressions passed with arguments, such as to Where and Set, are not
+// checked; their arguments are passed separately from the SQL.
+func (q Query) Strict() Query {
+	q.strict = true
+	return q
+}
+
+type strictCheck int
+
+const (
+	noCheck strictCheck = iota
+	checkTable
+	checkColumn
+	checkColumns
+	checkName
+	checkOrder
+	checkLiteral
+)
+
+const (
+	identPattern = "(?:[A-Za-z_][A-Za-z0-9_$]*|\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\])"
+	namePattern  = identPattern + `(?:\.` + identPattern + `)*`
+	aliasPattern = `(?:\s+(?:(?i:AS)\s+)?` + identPattern + `)?`
+)
+
+var (
+	tablePattern  = regexp.MustCompile(`^` + namePattern + aliasPattern + `$`)
+	columnPattern = regexp.MustCompile(`^(?:\*|` + namePattern + `(?:\.\*)?)` + aliasPattern + `$`)
+	orderPattern  = regexp.MustCompile(`^` + namePattern +
+		`(?i:\s+(?:ASC|DESC))?(?i:\s+NULLS\s+(?:FIRST|LAST))?$`)
+)
+
+func (c strictCheck) String() string {
+	switch c {
+	case checkTable:
+		return "table"
+	case checkColumn, checkColumns, checkName:
+		return "column"
+	case checkOrder:
+		return "ORDER BY term"
+	default:
+		return "literal"
+	}
+}
+
+// check returns an error if s is not what c accepts.
+func (c strictCheck) check(s string) error {
+	if c == noCheck {
+		return nil
+	}
+
+	var ok bool
+	switch c {
+	case checkTable:
+		ok = tablePattern.MatchString(s)
+	case checkColumn:
+		ok = columnPattern.MatchString(s)
+	case checkColumns:
+		ok = true
+		for _, column := range strings.Split(s, ",") {
+			ok = ok && columnPattern.MatchString(strings.TrimSpace(column))
+		}
+	case checkName:
+		ok = funcNamePattern.MatchString(s)
+	case checkOrder:
+		ok = orderPattern.MatchString(s)
+	}
+
+	if !ok {
+		return fmt.Errorf("qb: strict: %q is not a valid %s; use qb.Raw to write raw SQL", s, c)
+	}
+	return nil
+}
+
+// checkStrict checks the tokens of a strict query, and of the strict queries
+// nested in it.
+func (q *sqlWriter) checkStrict(strict bool) error {
+	for _, t := range q.tokens {
+		var err error
+		switch f := t.frag.(type) {
+		case nil:
+			if strict {
+				err = t.check.check(t.sql)
+			}
+		case lockedTable:
+			if strict {
+				err = checkTable.check(f.sql)
+			}
+		case nullsOrder:
+			if strict {
+				err = checkOrder.check(f.sql)
+			}
+		case condition:
+			err = f.p.w.checkStrict(strict)
+		case nestedQuery:
+			err = f.q.w.checkStrict(strict || f.q.strict)
+		case CaseExpr:
+			if err = f.w.checkStrict(strict); err == nil {
+				err = f.els.checkStrict(strict)
+			}
+		case FuncExpr:
+			for i := 0; i < len(f.args) && err == nil; i++ {
+				err = f.args[i].checkStrict(strict)
+			}
+			if err == nil {
+				err = f.filter.w.checkStrict(strict)
+			}
+		case arrayAny:
+			if strict {
+				err = checkColumn.check(string(f.c))
+			}
+		case arrayOp:
+			if strict {
+				err = checkColumn.check(string(f.c))
+			}
+		case JSONExpr:
+			if strict {
+				err = checkColumn.check(f.p.column)
+			}
+		case RankExpr:
+			for i := 0; i < len(f.m.columns) && strict && err == nil; i++ {
+				err = checkColumn.check(f.m.columns[i])
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SortableColumns lists the columns by which a query may be sorted.
+type SortableColumns []string
+
+// Parses a comma separated list of sort terms, such as from the query string
+// of a request, into ORDER BY terms. A term is a column, optionally followed
+// by its direction, or preceded by a minus sign for a descending sort:
+//  name desc,-created_at
+// returns
+//  []string{"name DESC", "created_at DESC"}
+// It is an error to sort by a column which is not listed. See ParseSort.
+func (cs SortableColumns) Parse(input string) ([]string, error) {
+	allowed := make(map[string]string, len(cs))
+	for _, c := range cs {
+		allowed[c] = c
+	}
+	return ParseSort(input, allowed)
+}
@@ -0,0 +1,177 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestStrict(t *testing.T) {
+	t.Run("accepted", func(t *testing.T) {
+		q := qb.Strict().
+			Select("u.id", "u.*", `"name" AS n`).
+			SelectColumn("?", qb.Raw("count(*) AS total")).
+			FromAs("public.users", "u").
+			LeftJoinUsing("orgs", "org_id").
+			Where(qb.Pred("u.created_at < ?", qb.Raw("now()"))).
+			Where(qb.Pred("u.deleted_at IS ?", qb.NULL)).
+			Where(qb.Col("u.active").Eq(true)).
+			GroupBy("u.id", "u.name").
+			OrderBy("u.name DESC NULLS LAST", "id")
+
+		require.Equal(t, `SELECT u.id , u.* , "name" AS n ,  count(*) AS total FROM public.users AS "u" LEFT JOIN orgs USING ( org_id ) WHERE u.created_at < now() AND u.deleted_at IS NULL AND u.active = ? GROUP BY u.id, u.name ORDER BY u.name DESC NULLS LAST , id`, q.SQL())
+		require.Equal(t, []interface{}{true}, q.Args())
+	})
+
+	rejected := []struct {
+		name  string
+		err   string
+		query func() qb.Query
+	}{
+		{
+			name: "select",
+			err:  `qb: strict: "id; DROP TABLE users" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("id; DROP TABLE users").From("users")
+			},
+		},
+		{
+			name: "from",
+			err:  `qb: strict: "users WHERE 1 = 1" is not a valid table; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("users WHERE 1 = 1")
+			},
+		},
+		{
+			name: "join",
+			err:  `qb: strict: "orgs o, secrets" is not a valid table; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("users").JoinOn("orgs o, secrets", qb.Pred("o.id = org_id"))
+			},
+		},
+		{
+			name: "order by",
+			err:  `qb: strict: "(SELECT 1)" is not a valid ORDER BY term; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("users").OrderBy("id", "(SELECT 1)")
+			},
+		},
+		{
+			name: "group by",
+			err:  `qb: strict: "a, b()" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("users").GroupBy("a", "b()")
+			},
+		},
+		{
+			name: "lit",
+			err:  `qb: strict: "now()" is not a valid literal; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("users").Where(qb.Pred("created_at < ?", qb.Lit("now()")))
+			},
+		},
+		{
+			name: "forged raw",
+			err:  `qb: strict: "\x00qb.Raw(users; DROP TABLE x --)\x00" is not a valid table; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("\x00qb.Raw(users; DROP TABLE x --)\x00")
+			},
+		},
+		{
+			name: "col predicate",
+			err:  `qb: strict: "x; DROP TABLE t" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("t").Where(qb.Col("x; DROP TABLE t").Eq(1))
+			},
+		},
+		{
+			name: "col null predicate",
+			err:  `qb: strict: "x IS NULL OR 1" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("t").Where(qb.Col("x IS NULL OR 1").IsNotNull())
+			},
+		},
+		{
+			name: "array predicate",
+			err:  `qb: strict: "tags) OR (1" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("t").Where(qb.Col("tags) OR (1").ArrayOverlaps([]string{"a"}))
+			},
+		},
+		{
+			name: "insert columns",
+			err:  `qb: strict: "a) SELECT 1; --" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.InsertInto("t", "a) SELECT 1; --").Values(1)
+			},
+		},
+		{
+			name: "set values",
+			err:  `qb: strict: "a = 1; --" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Update("t").SetValues(qb.Values{"a = 1; --": 1})
+			},
+		},
+		{
+			name: "returning",
+			err:  `qb: strict: "id; DROP TABLE t" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.DeleteFrom("t").Returning("id; DROP TABLE t")
+			},
+		},
+		{
+			name: "subquery",
+			err:  `qb: strict: "1; --" is not a valid column; use qb.Raw to write raw SQL`,
+			query: func() qb.Query {
+				return qb.Select("*").From("users").Where(qb.Pred("id IN ?", qb.Select("1; --")))
+			},
+		},
+	}
+
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query()
+			require.NotPanics(t, func() { q.SQL() })
+
+			q = q.Strict()
+			require.EqualError(t, recoverError(func() { q.SQL() }), tt.err)
+			require.EqualError(t, recoverError(func() { q.Args() }), tt.err)
+		})
+	}
+
+	t.Run("strict subquery", func(t *testing.T) {
+		q := qb.Select("*").From("users").Where(qb.Pred("id IN ?", qb.Strict().Select("id").From("t; --")))
+		require.Panics(t, func() { q.SQL() })
+	})
+}
+
+func TestSortableColumns_Parse(t *testing.T) {
+	cs := qb.SortableColumns{"name", "created_at"}
+
+	terms, err := cs.Parse("name desc, -created_at")
+	require.NoError(t, err)
+	require.Equal(t, []string{"name DESC", "created_at DESC"}, terms)
+
+	terms, err = cs.Parse("+name,created_at ASC,")
+	require.NoError(t, err)
+	require.Equal(t, []string{"name ASC", "created_at ASC"}, terms)
+
+	_, err = cs.Parse("password")
+	require.EqualError(t, err, `qb: cannot sort by "password"`)
+
+	_, err = cs.Parse("name sideways")
+	require.EqualError(t, err, `qb: invalid sort direction "sideways"`)
+
+	_, err = cs.Parse("-name desc")
+	require.EqualError(t, err, `qb: invalid sort term "-name desc"`)
+}
+
+func recoverError(f func()) (err error) {
+	defer func() {
+		err, _ = recover().(error)
+	}()
+	f()
+	return nil
+}
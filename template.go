@@ -0,0 +1,88 @@
+package qb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A Param is a named argument of a query, whose value is given when the query
+// is bound with Template.Bind:
+//  qb.Select("*").From("users").Where(qb.Pred("id = ?", qb.Param("user_id")))
+type Param string
+
+// Args are the values of the parameters of a template, by name.
+type Args map[string]interface{}
+
+// A Template is a query which has been rendered once, and whose parameters
+// are bound to values for each execution. Templates are safe for concurrent
+// use.
+type Template struct {
+	sql    string
+	args   []interface{}
+	params []int
+	names  map[string]bool
+}
+
+// Renders the query into a template. The query is rendered as by SQL() and
+// Args(): the scopes registered when the template is created apply to every
+// execution of the template, and the arguments of the query which are not
+// Params are bound to each execution as they are.
+//  tmpl := qb.WithDialectPQ().Select("*").From("users").
+//  	Where(qb.Pred("id = ?", qb.Param("user_id"))).
+//  	Template()
+//
+//  sql, args, err := tmpl.Bind(qb.Args{"user_id": 42})
+func (q Query) Template() Template {
+	t := Template{
+		sql:   q.SQL(),
+		args:  q.Args(),
+		names: map[string]bool{},
+	}
+
+	for i, arg := range t.args {
+		if p, ok := arg.(Param); ok {
+			t.params = append(t.params, i)
+			t.names[string(p)] = true
+		}
+	}
+	return t
+}
+
+func (t Template) SQL() string {
+	return t.sql
+}
+
+// Params returns the names of the parameters of the template, in sorted order.
+func (t Template) Params() []string {
+	names := make([]string, 0, len(t.names))
+	for name := range t.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Binds the parameters of the template to the given values, and returns the
+// SQL and the arguments to execute it with. Every parameter must be given a
+// value, and every value must be for a parameter of the template.
+func (t Template) Bind(args Args) (string, []interface{}, error) {
+	if len(args) != len(t.names) {
+		for name := range args {
+			if !t.names[name] {
+				return "", nil, fmt.Errorf("qb: unknown parameter %q", name)
+			}
+		}
+	}
+
+	bound := make([]interface{}, len(t.args))
+	copy(bound, t.args)
+	for _, i := range t.params {
+		name := string(t.args[i].(Param))
+		v, ok := args[name]
+		if !ok {
+			return "", nil, fmt.Errorf("qb: no argument for parameter %q", name)
+		}
+		bound[i] = v
+	}
+	return t.sql, bound, nil
+}
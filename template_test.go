@@ -0,0 +1,61 @@
+package qb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestTemplate(t *testing.T) {
+	tmpl := qb.WithDialectPQ().Select("*").From("users").
+		Where(qb.Pred("org_id = ?", qb.Param("org_id"))).
+		Where(qb.Pred("active = ?", true)).
+		Where(qb.Pred("id = ? OR parent_id = ?", qb.Param("user_id"), qb.Param("user_id"))).
+		Template()
+
+	require.Equal(t, `SELECT * FROM users WHERE org_id = $1 AND active = $2 AND id = $3 OR parent_id = $4`, tmpl.SQL())
+	require.Equal(t, []string{"org_id", "user_id"}, tmpl.Params())
+
+	sql, args, err := tmpl.Bind(qb.Args{"org_id": 1, "user_id": 42})
+	require.NoError(t, err)
+	require.Equal(t, tmpl.SQL(), sql)
+	require.Equal(t, []interface{}{1, true, 42, 42}, args)
+
+	_, args, err = tmpl.Bind(qb.Args{"org_id": 2, "user_id": 43})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{2, true, 43, 43}, args)
+
+	_, _, err = tmpl.Bind(qb.Args{"org_id": 1})
+	require.EqualError(t, err, `qb: no argument for parameter "user_id"`)
+
+	_, _, err = tmpl.Bind(qb.Args{"org_id": 1, "user_id": 42, "userid": 42})
+	require.EqualError(t, err, `qb: unknown parameter "userid"`)
+}
+
+func BenchmarkTemplate_Bind(b *testing.B) {
+	q := qb.WithDialectPQ().Select("id", "name").From("users").
+		Where(qb.Pred("org_id = ?", qb.Param("org_id")).And("name LIKE ?", qb.Param("name"))).
+		OrderBy("name").
+		Limit(10)
+
+	b.Run("Build", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q.WithArgs().Build()
+		}
+	})
+
+	b.Run("Bind", func(b *testing.B) {
+		tmpl := q.Template()
+		args := qb.Args{"org_id": 1, "name": "a%"}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := tmpl.Bind(args); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package qb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TextSearch is a full-text search of columns, in the full-text search of
+// each dialect:
+//  pq:     to_tsvector(column) @@ websearch_to_tsquery(?)
+//  mysql:  MATCH (column0, column1) AGAINST (? IN BOOLEAN MODE)
+//  mssql:  CONTAINS((column0, column1), ?)
+//  sqlite: column MATCH ?
+// The columns must have a full-text index in mysql and mssql. In sqlite, the
+// column is an FTS5 table, or one of its columns. The syntax of the query is
+// that of the dialect.
+type TextSearch struct {
+	columns []string
+	lang    string
+}
+
+func Match(columns ...string) TextSearch {
+	return TextSearch{columns: columns}
+}
+
+// Sets the text search configuration used in the pq dialect, such as english.
+//  to_tsvector('english', column) @@ websearch_to_tsquery('english', ?)
+func (m TextSearch) Config(name string) TextSearch {
+	m.lang = name
+	return m
+}
+
+// Returns the predicate which matches rows whose columns match the query.
+func (m TextSearch) Against(query string) Predicate {
+	var pred Predicate
+	pred.count = 1
+	pred.w.WriteFragment(textMatch{m, query})
+	return pred
+}
+
+// Returns the relevance of the columns to the query, where higher values are
+// more relevant. Like FuncExpr, it is passed as an argument wherever
+// expressions take arguments, such as to SelectColumn and OrderByExpr:
+//  m := qb.Match("name", "bio")
+//  q = q.Where(m.Against(search)).OrderByExpr("? DESC", m.Rank(search))
+//  pq:     ts_rank(to_tsvector(...), websearch_to_tsquery(?))
+//  mysql:  MATCH (...) AGAINST (? IN BOOLEAN MODE)
+//  sqlite: -rank
+// Ranking is not supported in the mssql dialect, where it takes a join with
+// CONTAINSTABLE.
+func (m TextSearch) Rank(query string) RankExpr {
+	return RankExpr{m, query}
+}
+
+// tsvector returns the text search vector of the columns in the pq dialect.
+func (m TextSearch) tsvector() string {
+	doc := m.columns[0]
+	if len(m.columns) > 1 {
+		cs := make([]string, len(m.columns))
+		for i, c := range m.columns {
+			cs[i] = "coalesce(" + c + ", '')"
+		}
+		doc = strings.Join(cs, " || ' ' || ")
+	}
+	if cfg := m.config(); cfg != "" {
+		doc = cfg + " " + doc
+	}
+	return "to_tsvector(" + doc + ")"
+}
+
+// config returns the configuration argument of the pq text search functions,
+// followed by a comma, or "" if no configuration is set.
+func (m TextSearch) config() string {
+	if m.lang == "" {
+		return ""
+	}
+	return quoteString(m.lang) + ","
+}
+
+// write writes the text search of the columns for the query, which is a
+// predicate or a rank.
+func (m TextSearch) write(w *sqlWriter, d Dialect, query string, rank bool) error {
+	if len(m.columns) == 0 {
+		return fmt.Errorf("qb: full-text search without columns")
+	}
+
+	switch d {
+	case DialectDefault, DialectPq:
+		if rank {
+			w.WriteSQL("ts_rank(" + m.tsvector() + ", websearch_to_tsquery(" + m.config())
+			w.WriteArg(query)
+			w.WriteSQL("))")
+		} else {
+			w.WriteSQL(m.tsvector() + " @@ websearch_to_tsquery(" + m.config())
+			w.WriteArg(query)
+			w.WriteSQL(")")
+		}
+	case DialectMysql:
+		w.WriteSQL("MATCH (" + strings.Join(m.columns, ", ") + ") AGAINST (")
+		w.WriteArg(query)
+		w.WriteSQL("IN BOOLEAN MODE)")
+	case DialectMssql:
+		if rank {
+			return fmt.Errorf("qb: full-text rank is not supported in the mssql dialect")
+		}
+
+		columns := m.columns[0]
+		if len(m.columns) > 1 {
+			columns = "(" + strings.Join(m.columns, ", ") + ")"
+		}
+		w.WriteSQL("CONTAINS(" + columns + ",")
+		w.WriteArg(query)
+		w.WriteSQL(")")
+	case DialectSqlite:
+		if len(m.columns) > 1 {
+			return fmt.Errorf("qb: full-text search of several columns is not supported in the sqlite dialect; match the FTS5 table instead")
+		}
+
+		if rank {
+			w.WriteSQL("-rank")
+		} else {
+			w.WriteSQL(m.columns[0], "MATCH")
+			w.WriteArg(query)
+		}
+	default:
+		return fmt.Errorf("qb: full-text search is not supported in the %s dialect", dialectName(d))
+	}
+	return nil
+}
+
+type textMatch struct {
+	m     TextSearch
+	query string
+}
+
+func (t textMatch) writeTo(w *sqlWriter, d Dialect) error {
+	return t.m.write(w, d, t.query, false)
+}
+
+// RankExpr is the relevance of the columns of a text search to a query.
+type RankExpr struct {
+	m     TextSearch
+	query string
+}
+
+func (r RankExpr) writeTo(w *sqlWriter, d Dialect) error {
+	return r.m.write(w, d, r.query, true)
+}
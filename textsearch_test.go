@@ -0,0 +1,91 @@
+package qb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+func TestMatch(t *testing.T) {
+	m := qb.Match("name", "bio")
+	query := func(d qb.Dialect, m qb.TextSearch) qb.Query {
+		return qb.DialectOption(d).
+			Select("id").SelectColumn("? AS score", m.Rank("go sql")).
+			From("members").
+			Where(m.Against("go sql")).
+			Where(qb.Pred("active = ?", true)).
+			OrderByExpr("? DESC", m.Rank("go sql"))
+	}
+
+	tests := []struct {
+		dialect qb.Dialect
+		m       qb.TextSearch
+		expr    string
+		args    []interface{}
+		err     string
+	}{
+		{
+			dialect: qb.DialectPq,
+			m:       m,
+			expr:    `SELECT id ,  ts_rank(to_tsvector(coalesce(name, '') || ' ' || coalesce(bio, '')), websearch_to_tsquery( $1 ))  AS score FROM members WHERE to_tsvector(coalesce(name, '') || ' ' || coalesce(bio, '')) @@ websearch_to_tsquery( $2 ) AND active = $3 ORDER BY  ts_rank(to_tsvector(coalesce(name, '') || ' ' || coalesce(bio, '')), websearch_to_tsquery( $4 ))  DESC`,
+			args:    []interface{}{"go sql", "go sql", true, "go sql"},
+		},
+		{
+			dialect: qb.DialectPq,
+			m:       qb.Match("name").Config("english"),
+			expr:    `SELECT id ,  ts_rank(to_tsvector('english', name), websearch_to_tsquery('english', $1 ))  AS score FROM members WHERE to_tsvector('english', name) @@ websearch_to_tsquery('english', $2 ) AND active = $3 ORDER BY  ts_rank(to_tsvector('english', name), websearch_to_tsquery('english', $4 ))  DESC`,
+			args:    []interface{}{"go sql", "go sql", true, "go sql"},
+		},
+		{
+			dialect: qb.DialectMysql,
+			m:       m,
+			expr:    `SELECT id ,  MATCH (name, bio) AGAINST ( ? IN BOOLEAN MODE)  AS score FROM members WHERE MATCH (name, bio) AGAINST ( ? IN BOOLEAN MODE) AND active = ? ORDER BY  MATCH (name, bio) AGAINST ( ? IN BOOLEAN MODE)  DESC`,
+			args:    []interface{}{"go sql", "go sql", true, "go sql"},
+		},
+		{
+			dialect: qb.DialectSqlite,
+			m:       qb.Match("members_fts"),
+			expr:    `SELECT id ,  -rank  AS score FROM members WHERE members_fts MATCH ? AND active = ? ORDER BY  -rank  DESC`,
+			args:    []interface{}{"go sql", true},
+		},
+		{
+			dialect: qb.DialectSqlite,
+			m:       m,
+			err:     "qb: full-text search of several columns is not supported in the sqlite dialect; match the FTS5 table instead",
+		},
+		{
+			dialect: qb.DialectMssql,
+			m:       m,
+			err:     "qb: full-text rank is not supported in the mssql dialect",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.dialect), func(t *testing.T) {
+			q := query(tt.dialect, tt.m)
+			if tt.err != "" {
+				require.EqualError(t, recoverError(func() { q.SQL() }), tt.err)
+				return
+			}
+			require.Equal(t, tt.expr, q.SQL())
+			require.Equal(t, tt.args, q.Args())
+		})
+	}
+
+	t.Run("mssql", func(t *testing.T) {
+		q := qb.WithDialectMssql().Select("*").From("members").Where(m.Against("go")).Where(qb.Match("name").Against("sql"))
+		require.Equal(t, `SELECT * FROM members WHERE CONTAINS((name, bio), @p1 ) AND CONTAINS(name, @p2 )`, q.SQL())
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		q := qb.WithDialectPQ().Strict().Select("id").From("members").OrderByExpr("?", qb.Match("name").Rank("go"))
+		require.Equal(t, `SELECT id FROM members ORDER BY  ts_rank(to_tsvector(name), websearch_to_tsquery( $1 ))`, q.SQL())
+		require.Equal(t, []interface{}{"go"}, q.Args())
+
+		q = qb.Strict().Select("id").From("members").OrderByExpr("?", qb.Match("name, (SELECT 1)").Rank("go"))
+		require.EqualError(t, recoverError(func() { q.SQL() }), `qb: strict: "name, (SELECT 1)" is not a valid column; use qb.Raw to write raw SQL`)
+	})
+}
@@ -0,0 +1,278 @@
+package qb
+
+import (
+	"fmt"
+)
+
+type IsolationLevel int
+
+const (
+	IsolationDefault IsolationLevel = iota
+	ReadUncommitted
+	ReadCommitted
+	RepeatableRead
+	Serializable
+)
+
+func (level IsolationLevel) String() string {
+	switch level {
+	case ReadUncommitted:
+		return "READ UNCOMMITTED"
+	case ReadCommitted:
+		return "READ COMMITTED"
+	case RepeatableRead:
+		return "REPEATABLE READ"
+	case Serializable:
+		return "SERIALIZABLE"
+	default:
+		return ""
+	}
+}
+
+// beginTransaction is the fragment written by Begin.
+type beginTransaction struct {
+	isolation  IsolationLevel
+	readOnly   bool
+	deferrable bool
+}
+
+// setIsolation is the statement which sets the isolation level of the next
+// transaction in the mysql and mssql dialects, where it precedes the statement
+// which begins the transaction.
+type setIsolation struct {
+	isolation IsolationLevel
+}
+
+func (s setIsolation) writeTo(w *sqlWriter, d Dialect) error {
+	w.WriteSQL("SET TRANSACTION ISOLATION LEVEL", s.isolation.String())
+	return nil
+}
+
+// statements returns the statements of a query which begins a transaction
+// with an isolation level in the mysql or mssql dialect, where the level is
+// set by a statement of its own, or otherwise the query itself.
+func (b beginTransaction) statements(q Query) []Query {
+	if b.isolation == IsolationDefault || q.Dialect != DialectMysql && q.Dialect != DialectMssql {
+		return []Query{q}
+	}
+
+	set, begin := q, q
+	set.w = sqlWriter{}
+	set.w.WriteFragment(setIsolation{b.isolation})
+
+	b.isolation = IsolationDefault
+	begin.w = sqlWriter{}
+	begin.w.WriteFragment(b)
+	return []Query{set, begin}
+}
+
+func (b beginTransaction) writeTo(w *sqlWriter, d Dialect) error {
+	switch d {
+	case DialectMysql, DialectMssql:
+		if b.isolation != IsolationDefault {
+			setIsolation{b.isolation}.writeTo(w, d)
+			w.WriteSQL(";")
+		}
+
+		if d == DialectMssql {
+			w.WriteSQL("BEGIN TRANSACTION")
+			return nil
+		}
+
+		w.WriteSQL("START TRANSACTION")
+		if b.readOnly {
+			w.WriteSQL("READ ONLY")
+		}
+	case DialectGoracle:
+		// Transactions begin implicitly in goracle, so only the
+		// characteristics of the transaction are set.
+		switch {
+		case b.isolation == IsolationDefault && !b.readOnly:
+			return fmt.Errorf("qb: BEGIN without an isolation level or READ ONLY is not supported in the goracle dialect, where transactions begin implicitly")
+		case b.isolation == ReadUncommitted, b.isolation == RepeatableRead:
+			return fmt.Errorf("qb: isolation level %s is not supported in the goracle dialect", b.isolation)
+		case b.isolation != IsolationDefault && b.readOnly:
+			return fmt.Errorf("qb: a read-only transaction cannot set an isolation level in the goracle dialect")
+		}
+
+		if b.isolation != IsolationDefault {
+			w.WriteSQL("SET TRANSACTION ISOLATION LEVEL", b.isolation.String())
+		}
+
+		if b.readOnly {
+			w.WriteSQL("SET TRANSACTION READ ONLY")
+		}
+	case DialectSqlite:
+		// sqlite transactions are always serializable.
+		w.WriteSQL("BEGIN")
+	default:
+		w.WriteSQL("BEGIN")
+		if b.isolation != IsolationDefault {
+			w.WriteSQL("ISOLATION LEVEL", b.isolation.String())
+		}
+
+		if b.readOnly {
+			w.WriteSQL("READ ONLY")
+		}
+
+		if b.deferrable {
+			w.WriteSQL("DEFERRABLE")
+		}
+	}
+
+	return nil
+}
+
+// transactionStatement is the fragment written by the statements which end a
+// transaction or manage its savepoints.
+type transactionStatement struct {
+	kind      string
+	savepoint string
+}
+
+func (s transactionStatement) writeTo(w *sqlWriter, d Dialect) error {
+	if d == DialectMssql {
+		switch s.kind {
+		case "COMMIT":
+			w.WriteSQL("COMMIT TRANSACTION")
+		case "ROLLBACK":
+			w.WriteSQL("ROLLBACK TRANSACTION")
+		case "SAVEPOINT":
+			w.WriteSQL("SAVE TRANSACTION", s.savepoint)
+		case "ROLLBACK TO SAVEPOINT":
+			w.WriteSQL("ROLLBACK TRANSACTION", s.savepoint)
+		case "RELEASE SAVEPOINT":
+			return fmt.Errorf("qb: RELEASE SAVEPOINT is not supported in the mssql dialect")
+		}
+		return nil
+	}
+
+	if d == DialectGoracle && s.kind == "RELEASE SAVEPOINT" {
+		return fmt.Errorf("qb: RELEASE SAVEPOINT is not supported in the goracle dialect")
+	}
+
+	w.WriteSQL(s.kind)
+	if s.savepoint != "" {
+		w.WriteSQL(s.savepoint)
+	}
+	return nil
+}
+
+func (q Query) transaction(f fragment) Query {
+	q.last = transactionExpr
+	q.w.WriteFragment(f)
+	return q
+}
+
+func (q Query) lastBegin(method string) beginTransaction {
+	b, ok := q.w.lastFragment().(beginTransaction)
+	if !ok || q.last != transactionExpr {
+		panic("qb: " + method + " must follow Begin")
+	}
+	return b
+}
+
+func Begin() Query {
+	return Query{}.Begin()
+}
+
+// Begins a transaction. The statement is rendered according to the dialect:
+//  BEGIN
+//  START TRANSACTION (mysql)
+//  BEGIN TRANSACTION (mssql)
+// In the goracle dialect, where transactions begin implicitly, only the
+// transaction's characteristics are set, and a Begin without an isolation
+// level or ReadOnly fails to render.
+func (q Query) Begin() Query {
+	return q.transaction(beginTransaction{})
+}
+
+// Sets the isolation level of the transaction begun by the preceding Begin.
+// This is ignored in the sqlite dialect, and only READ COMMITTED and
+// SERIALIZABLE are supported in the goracle dialect.
+//  BEGIN ISOLATION LEVEL level
+//  SET TRANSACTION ISOLATION LEVEL level ; START TRANSACTION (mysql)
+//  SET TRANSACTION ISOLATION LEVEL level ; BEGIN TRANSACTION (mssql)
+// In the mysql and mssql dialects, Statements and Batch split these into two
+// statements.
+func (q Query) IsolationLevel(level IsolationLevel) Query {
+	b := q.lastBegin("IsolationLevel")
+	b.isolation = level
+	q.w.replace(q.w.Len()-1, b)
+	return q
+}
+
+// Makes the transaction begun by the preceding Begin read-only. This is
+// ignored in the mssql and sqlite dialects.
+//  BEGIN READ ONLY
+func (q Query) ReadOnly() Query {
+	b := q.lastBegin("ReadOnly")
+	b.readOnly = true
+	q.w.replace(q.w.Len()-1, b)
+	return q
+}
+
+// Makes the transaction begun by the preceding Begin deferrable. This is only
+// rendered in the pq and default dialects.
+//  BEGIN DEFERRABLE
+func (q Query) Deferrable() Query {
+	b := q.lastBegin("Deferrable")
+	b.deferrable = true
+	q.w.replace(q.w.Len()-1, b)
+	return q
+}
+
+func Commit() Query {
+	return Query{}.Commit()
+}
+
+// Commits the current transaction.
+//  COMMIT
+//  COMMIT TRANSACTION (mssql)
+func (q Query) Commit() Query {
+	return q.transaction(transactionStatement{kind: "COMMIT"})
+}
+
+func Rollback() Query {
+	return Query{}.Rollback()
+}
+
+// Rolls back the current transaction.
+//  ROLLBACK
+//  ROLLBACK TRANSACTION (mssql)
+func (q Query) Rollback() Query {
+	return q.transaction(transactionStatement{kind: "ROLLBACK"})
+}
+
+func Savepoint(name string) Query {
+	return Query{}.Savepoint(name)
+}
+
+// Defines a savepoint in the current transaction.
+//  SAVEPOINT name
+//  SAVE TRANSACTION name (mssql)
+func (q Query) Savepoint(name string) Query {
+	return q.transaction(transactionStatement{kind: "SAVEPOINT", savepoint: name})
+}
+
+func RollbackTo(name string) Query {
+	return Query{}.RollbackTo(name)
+}
+
+// Rolls back the current transaction to a savepoint.
+//  ROLLBACK TO SAVEPOINT name
+//  ROLLBACK TRANSACTION name (mssql)
+func (q Query) RollbackTo(name string) Query {
+	return q.transaction(transactionStatement{kind: "ROLLBACK TO SAVEPOINT", savepoint: name})
+}
+
+func ReleaseSavepoint(name string) Query {
+	return Query{}.ReleaseSavepoint(name)
+}
+
+// Releases a savepoint. This is not supported in the mssql and goracle
+// dialects, which fail to render it.
+//  RELEASE SAVEPOINT name
+func (q Query) ReleaseSavepoint(name string) Query {
+	return q.transaction(transactionStatement{kind: "RELEASE SAVEPOINT", savepoint: name})
+}
@@ -0,0 +1,233 @@
+package qb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+// A TxBeginner begins database transactions, e.g. *sql.DB or *sql.Conn.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Tx is a transaction in which queries can be run.
+type Tx struct {
+	Tx *sql.Tx
+}
+
+// Exec, Query and QueryRow render the query once, and return the error if it
+// cannot be rendered instead of running it.
+func (tx *Tx) Exec(ctx context.Context, q Query) (sql.Result, error) {
+	s, args, err := q.TryBuild()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Tx.ExecContext(ctx, s, args...)
+}
+
+func (tx *Tx) Query(ctx context.Context, q Query) (*sql.Rows, error) {
+	s, args, err := q.TryBuild()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Tx.QueryContext(ctx, s, args...)
+}
+
+func (tx *Tx) QueryRow(ctx context.Context, q Query) *Row {
+	s, args, err := q.TryBuild()
+	if err != nil {
+		return &Row{err: err}
+	}
+	return &Row{Row: tx.Tx.QueryRowContext(ctx, s, args...)}
+}
+
+// Row is the result of QueryRow: a *sql.Row, or the error with which the query
+// could not be rendered, which Scan and Err return.
+type Row struct {
+	*sql.Row
+	err error
+}
+
+func (r *Row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Row.Scan(dest...)
+}
+
+func (r *Row) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Row.Err()
+}
+
+type TxOptions struct {
+	// Isolation and ReadOnly are passed to BeginTx.
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+
+	// MaxAttempts is the number of times the transaction is attempted before
+	// giving up. Zero means three attempts.
+	MaxAttempts int
+
+	// Backoff returns how long to wait before the given retry, starting from
+	// one. Nil means exponential backoff with jitter starting at 10ms.
+	Backoff func(retry int) time.Duration
+
+	// IsRetryable reports whether a failed transaction should be retried.
+	// Nil means IsRetryable.
+	IsRetryable func(err error) bool
+}
+
+// InTx runs f in a transaction on db, which is committed if f returns nil and
+// rolled back otherwise. If f or the commit fails with a retryable error, the
+// whole transaction is retried. The error of the last attempt is returned.
+func InTx(ctx context.Context, db TxBeginner, opts *TxOptions, f func(tx *Tx) error) error {
+	var o TxOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+
+	if o.Backoff == nil {
+		o.Backoff = defaultBackoff
+	}
+
+	if o.IsRetryable == nil {
+		o.IsRetryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, &o, f)
+		if err == nil || attempt >= o.MaxAttempts || !o.IsRetryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(o.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func runTx(ctx context.Context, db TxBeginner, o *TxOptions, f func(tx *Tx) error) (err error) {
+	sqlTx, err := db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: o.Isolation,
+		ReadOnly:  o.ReadOnly,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := f(&Tx{Tx: sqlTx}); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return sqlTx.Commit()
+}
+
+func defaultBackoff(retry int) time.Duration {
+	d := 10 * time.Millisecond << uint(retry-1)
+	if d > time.Second || d <= 0 {
+		d = time.Second
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IsRetryable reports whether err, or an error it wraps, is a serialization
+// failure or deadlock that is resolved by retrying the transaction. Errors are
+// recognised by their SQLSTATE (40001 and 40P01), or by the mysql error number
+// of deadlocks (1213), without depending on any particular driver.
+func IsRetryable(err error) bool {
+	for err != nil {
+		switch sqlState(err) {
+		case "40001", "40P01":
+			return true
+		}
+
+		switch errorNumber(err) {
+		case 1213:
+			return true
+		}
+
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+
+	return false
+}
+
+// sqlState returns the SQLSTATE of a driver error, as reported by a
+// SQLState() method (pgx, lib/pq) or a Code or SQLState field.
+func sqlState(err error) string {
+	if s, ok := err.(interface{ SQLState() string }); ok {
+		return s.SQLState()
+	}
+
+	for _, name := range []string{"Code", "SQLState"} {
+		f := errorField(err, name)
+		switch {
+		case !f.IsValid():
+		case f.Kind() == reflect.String:
+			return f.String()
+		case f.Kind() == reflect.Array && f.Type().Elem().Kind() == reflect.Uint8:
+			b := make([]byte, f.Len())
+			reflect.Copy(reflect.ValueOf(b), f)
+			return string(b)
+		}
+	}
+
+	return ""
+}
+
+// errorNumber returns the error number of a driver error with a Number field,
+// such as the mysql driver's.
+func errorNumber(err error) uint64 {
+	f := errorField(err, "Number")
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return f.Uint()
+	default:
+		return 0
+	}
+}
+
+func errorField(err error, name string) reflect.Value {
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+
+	return v.FieldByName(name)
+}
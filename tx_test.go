@@ -0,0 +1,222 @@
+package qb_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratom/qb"
+)
+
+// fakeConn is a database/sql driver connection which records the statements
+// executed on it, and fails them with the queued errors. Queries return rows.
+type fakeConn struct {
+	execs     []string
+	errs      []error
+	rows      [][]driver.Value
+	commits   int
+	rollbacks int
+	// rollbackErr is returned by Rollback.
+	rollbackErr error
+}
+
+func (c *fakeConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *fakeConn) Driver() driver.Driver                        { return nil }
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error)    { return fakeStmt{c, query}, nil }
+func (c *fakeConn) Close() error                                 { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                    { return c, nil }
+func (c *fakeConn) Commit() error                                { c.commits++; return nil }
+func (c *fakeConn) Rollback() error                              { c.rollbacks++; return c.rollbackErr }
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.execs = append(s.c.execs, fmt.Sprint(s.query, args))
+	if len(s.c.errs) > 0 {
+		err := s.c.errs[0]
+		s.c.errs = s.c.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.execs = append(s.c.execs, fmt.Sprint(s.query, args))
+	return &fakeRows{rows: s.c.rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type pqError struct{ code string }
+
+func (e *pqError) Error() string    { return "pq: " + e.code }
+func (e *pqError) SQLState() string { return e.code }
+
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlError) Error() string { return e.Message }
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	opts := &qb.TxOptions{Backoff: func(int) time.Duration { return 0 }}
+	q := qb.Update("t1").Set("a = ?", 1)
+
+	t.Run("commit", func(t *testing.T) {
+		conn := &fakeConn{}
+		err := qb.InTx(ctx, sql.OpenDB(conn), opts, func(tx *qb.Tx) error {
+			_, err := tx.Exec(ctx, q)
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"UPDATE t1 SET a = ?[1]"}, conn.execs)
+		require.Equal(t, 1, conn.commits)
+		require.Equal(t, 0, conn.rollbacks)
+	})
+
+	t.Run("retry serialization failure", func(t *testing.T) {
+		conn := &fakeConn{errs: []error{&pqError{"40001"}, &mysqlError{Number: 1213}}}
+		attempts := 0
+		err := qb.InTx(ctx, sql.OpenDB(conn), opts, func(tx *qb.Tx) error {
+			attempts++
+			_, err := tx.Exec(ctx, q)
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+		require.Equal(t, 1, conn.commits)
+		require.Equal(t, 2, conn.rollbacks)
+	})
+
+	t.Run("retry after failed rollback", func(t *testing.T) {
+		conn := &fakeConn{errs: []error{&pqError{"40001"}}, rollbackErr: errors.New("connection reset")}
+		attempts := 0
+		err := qb.InTx(ctx, sql.OpenDB(conn), opts, func(tx *qb.Tx) error {
+			attempts++
+			_, err := tx.Exec(ctx, q)
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, attempts)
+		require.Equal(t, 1, conn.commits)
+	})
+
+	t.Run("give up after max attempts", func(t *testing.T) {
+		conn := &fakeConn{errs: []error{&pqError{"40P01"}, &pqError{"40P01"}, &pqError{"40P01"}}}
+		err := qb.InTx(ctx, sql.OpenDB(conn), opts, func(tx *qb.Tx) error {
+			_, err := tx.Exec(ctx, q)
+			return err
+		})
+		require.Equal(t, &pqError{"40P01"}, err)
+		require.Len(t, conn.execs, 3)
+		require.Equal(t, 3, conn.rollbacks)
+	})
+
+	t.Run("no retry of other errors", func(t *testing.T) {
+		conn := &fakeConn{errs: []error{&pqError{"23505"}}}
+		err := qb.InTx(ctx, sql.OpenDB(conn), opts, func(tx *qb.Tx) error {
+			_, err := tx.Exec(ctx, q)
+			return err
+		})
+		require.Equal(t, &pqError{"23505"}, err)
+		require.Len(t, conn.execs, 1)
+	})
+
+	t.Run("custom classifier", func(t *testing.T) {
+		errBusy := errors.New("busy")
+		conn := &fakeConn{}
+		attempts := 0
+		err := qb.InTx(ctx, sql.OpenDB(conn), &qb.TxOptions{
+			Backoff:     opts.Backoff,
+			IsRetryable: func(err error) bool { return err == errBusy },
+		}, func(tx *qb.Tx) error {
+			attempts++
+			if attempts == 1 {
+				return errBusy
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, attempts)
+	})
+
+	t.Run("render error", func(t *testing.T) {
+		conn := &fakeConn{rows: [][]driver.Value{{int64(1)}}}
+		bad := qb.Strict().Select("*").From("t1; DROP TABLE t2")
+		want := `qb: strict: "t1; DROP TABLE t2" is not a valid table; use qb.Raw to write raw SQL`
+		err := qb.InTx(ctx, sql.OpenDB(conn), opts, func(tx *qb.Tx) error {
+			_, err := tx.Exec(ctx, bad)
+			require.EqualError(t, err, want)
+
+			_, err = tx.Query(ctx, bad)
+			require.EqualError(t, err, want)
+
+			var n int
+			require.EqualError(t, tx.QueryRow(ctx, bad).Scan(&n), want)
+			require.EqualError(t, tx.QueryRow(ctx, bad).Err(), want)
+
+			require.NoError(t, tx.QueryRow(ctx, qb.Select("1")).Scan(&n))
+			require.Equal(t, 1, n)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"SELECT 1[]"}, conn.execs)
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		conn := &fakeConn{}
+		require.Panics(t, func() {
+			_ = qb.InTx(ctx, sql.OpenDB(conn), opts, func(tx *qb.Tx) error {
+				panic("boom")
+			})
+		})
+		require.Equal(t, 1, conn.rollbacks)
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	require.True(t, qb.IsRetryable(&pqError{"40001"}))
+	require.True(t, qb.IsRetryable(&mysqlError{Number: 1213}))
+	require.False(t, qb.IsRetryable(&mysqlError{Number: 1205}))
+	require.True(t, qb.IsRetryable(fmt.Errorf("%w (rollback failed: %v)", &pqError{"40001"}, io.EOF)))
+	require.False(t, qb.IsRetryable(&mysqlError{Number: 1062}))
+	require.False(t, qb.IsRetryable(errors.New("40001")))
+	require.False(t, qb.IsRetryable(nil))
+}
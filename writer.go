@@ -2,55 +2,234 @@ package qb
 
 import (
 	"strings"
+	"sync/atomic"
 )
 
+// A fragment is a piece of SQL whose text depends on the dialect the query is
+// rendered in. Fragments are kept in the writer as-is, and only expanded into
+// plain tokens when the query is rendered.
+type fragment interface {
+	writeTo(w *sqlWriter, d Dialect) error
+}
+
+type token struct {
+	sql   string
+	arg   interface{}
+	isArg bool
+	frag  fragment
+	// check is what a strict query accepts as the SQL of the token.
+	check strictCheck
+	// n is the number of the placeholder of an argument, and reused is true
+	// if the argument is passed by an earlier placeholder with the same
+	// number. They are set when the query is rendered.
+	n      int
+	reused bool
+}
+
+// A sqlWriter is used like the append() built-in: writing to a copy of a
+// writer does not change the writer it was copied from. Writers share their
+// tokens with the writers they were copied from, and writing to a writer
+// appends to the shared array in place when no other writer has written past
+// its end; otherwise its tokens are copied first. Building a query is linear
+// in its number of tokens.
 type sqlWriter struct {
-	sql  []string
-	args []interface{}
+	tokens []token
+
+	// claimed is the number of tokens of the shared array which belong to a
+	// writer. It is nil if the array is not shared, e.g. after the tokens of
+	// the writer have been replaced.
+	claimed *int64
 }
 
 func (q *sqlWriter) SQL() []string {
-	return q.sql
+	w := q.mustExpand(DialectDefault)
+	sql := make([]string, len(w.tokens))
+	for i, t := range w.tokens {
+		sql[i] = t.sql
+	}
+	return sql
 }
 
 func (q *sqlWriter) Args() []interface{} {
-	return q.args
+	w := q.mustExpand(DialectDefault)
+	w.numberArgs(false, false)
+	return w.args()
+}
+
+// args returns the arguments of an expanded writer.
+func (q *sqlWriter) args() []interface{} {
+	args := []interface{}{}
+	for _, t := range q.tokens {
+		if t.isArg && !t.reused {
+			args = append(args, t.arg)
+		}
+	}
+	return args
 }
 
 func (q *sqlWriter) String() string {
-	return strings.Join(q.sql, " ")
+	return strings.Join(q.SQL(), " ")
 }
 
-func (q *sqlWriter) Append(w *sqlWriter) {
-	sql1 := make([]string, 0, len(q.sql)+len(w.sql))
-	sql1 = append(sql1, q.sql...)
-	sql1 = append(sql1, w.sql...)
+func (q *sqlWriter) write(ts ...token) {
+	n := len(q.tokens)
+	if q.claimed != nil && n+len(ts) <= cap(q.tokens) &&
+		atomic.CompareAndSwapInt64(q.claimed, int64(n), int64(n+len(ts))) {
+		q.tokens = append(q.tokens, ts...)
+		return
+	}
 
-	args1 := make([]interface{}, 0, len(q.args)+len(w.args))
-	args1 = append(args1, q.args...)
-	args1 = append(args1, w.args...)
+	size := 2 * (n + len(ts))
+	if size < 16 {
+		size = 16
+	}
 
-	q.sql, q.args = sql1, args1
+	tokens1 := make([]token, 0, size)
+	tokens1 = append(tokens1, q.tokens...)
+	tokens1 = append(tokens1, ts...)
+	q.tokens = tokens1
+	q.claimed = new(int64)
+	*q.claimed = int64(len(tokens1))
+}
+
+func (q *sqlWriter) Append(w *sqlWriter) {
+	q.write(w.tokens...)
 }
 
 func (q *sqlWriter) WriteSQL(s ...string) {
-	sql1 := make([]string, 0, len(q.sql)+len(s))
-	sql1 = append(sql1, q.sql...)
-	sql1 = append(sql1, s...)
-	q.sql = sql1
+	for _, sql := range s {
+		q.write(token{sql: sql})
+	}
+}
+
+// writeChecked writes SQL which a strict query checks.
+func (q *sqlWriter) writeChecked(check strictCheck, sql string) {
+	q.write(token{sql: sql, check: check})
 }
 
 func (q *sqlWriter) WriteArg(v interface{}) {
-	q.WriteSQL("?")
+	q.write(token{sql: "?", arg: v, isArg: true})
+}
+
+func (q *sqlWriter) WriteFragment(f fragment) {
+	q.write(token{frag: f})
+}
+
+// fragmentAt returns the i-th token if it is a fragment.
+func (q *sqlWriter) fragmentAt(i int) fragment {
+	if i < 0 || i >= len(q.tokens) {
+		return nil
+	}
+
+	return q.tokens[i].frag
+}
+
+// lastFragment returns the last token if it is a fragment.
+func (q *sqlWriter) lastFragment() fragment {
+	return q.fragmentAt(len(q.tokens) - 1)
+}
+
+// replace replaces the i-th token with the given fragment.
+func (q *sqlWriter) replace(i int, f fragment) {
+	tokens1 := make([]token, len(q.tokens))
+	copy(tokens1, q.tokens)
+	tokens1[i] = token{frag: f}
+	q.tokens = tokens1
+	q.claimed = nil
+}
+
+// replaceSQL replaces the i-th token with the given SQL.
+func (q *sqlWriter) replaceSQL(i int, sql string) {
+	tokens1 := make([]token, len(q.tokens))
+	copy(tokens1, q.tokens)
+	tokens1[i] = token{sql: sql}
+	q.tokens = tokens1
+	q.claimed = nil
+}
+
+// insert inserts tokens before the i-th token.
+func (q *sqlWriter) insert(i int, ts ...token) {
+	tokens1 := make([]token, 0, len(q.tokens)+len(ts))
+	tokens1 = append(tokens1, q.tokens[:i]...)
+	tokens1 = append(tokens1, ts...)
+	tokens1 = append(tokens1, q.tokens[i:]...)
+	q.tokens = tokens1
+	q.claimed = nil
+}
+
+func (q *sqlWriter) Len() int {
+	return len(q.tokens)
+}
+
+// An argMapper is a fragment which holds arguments.
+type argMapper interface {
+	mapArgs(f func(v interface{}) interface{}) fragment
+}
+
+// mapArgs returns a copy of the writer in which every argument v, including
+// those held by fragments, has been replaced by f(v).
+func (q *sqlWriter) mapArgs(f func(v interface{}) interface{}) sqlWriter {
+	out := sqlWriter{tokens: make([]token, len(q.tokens))}
+	for i, t := range q.tokens {
+		if t.isArg {
+			t.arg = f(t.arg)
+		}
+
+		if m, ok := t.frag.(argMapper); ok {
+			t.frag = m.mapArgs(f)
+		}
 
-	args1 := make([]interface{}, 0, len(q.args)+1)
-	args1 = append(args1, q.args...)
-	args1 = append(args1, v)
-	q.args = args1
+		out.tokens[i] = t
+	}
+	return out
+}
+
+// A queryMapper is a fragment which holds queries.
+type queryMapper interface {
+	mapQueries(f func(q Query) Query) fragment
 }
 
+// mapQueries returns a copy of the writer in which every query q held by a
+// fragment has been replaced by f(q).
+func (q *sqlWriter) mapQueries(f func(q Query) Query) sqlWriter {
+	out := sqlWriter{tokens: make([]token, len(q.tokens))}
+	for i, t := range q.tokens {
+		if m, ok := t.frag.(queryMapper); ok {
+			t.frag = m.mapQueries(f)
+		}
+
+		out.tokens[i] = t
+	}
+	return out
+}
+
+// WriteValue writes v the same way as an argument to WriteExpr.
+func (q *sqlWriter) WriteValue(v interface{}) {
+	switch x := v.(type) {
+	case literal:
+		q.writeChecked(checkLiteral, string(x))
+	case RawSQL:
+		q.WriteSQL(x.sql)
+	case Query:
+		q.WriteFragment(nestedQuery{q: x, parens: true})
+	case CaseExpr:
+		q.WriteFragment(x)
+	case FuncExpr:
+		q.WriteFragment(x)
+	case JSONExpr:
+		q.WriteFragment(x)
+	case RankExpr:
+		q.WriteFragment(x)
+	default:
+		q.WriteArg(x)
+	}
+}
+
+// WriteExpr writes an expression in which each ? stands for the next
+// argument, and ?? for a literal question mark.
 func (q *sqlWriter) WriteExpr(expr string, args ...interface{}) {
 	var i, iarg int
+	var escaped string
 	for {
 		s := expr[i:]
 		if len(s) == 0 {
@@ -59,23 +238,74 @@ func (q *sqlWriter) WriteExpr(expr string, args ...interface{}) {
 
 		j := strings.IndexRune(s, '?')
 		if j < 0 {
-			q.WriteSQL(s)
+			q.WriteSQL(escaped + s)
+			escaped = ""
 			break
 		}
 
-		q.WriteSQL(strings.TrimSpace(s[:j]))
-		switch x := args[iarg].(type) {
-		case literal:
-			q.WriteSQL(x.String())
-		case Query:
-			q.WriteSQL("(")
-			q.Append(&x.w)
-			q.WriteSQL(")")
-		default:
-			q.WriteArg(x)
+		if strings.HasPrefix(s[j:], "??") {
+			escaped += s[:j+1]
+			i += j + 2
+			continue
 		}
 
+		q.WriteSQL(strings.TrimSpace(escaped + s[:j]))
+		q.WriteValue(args[iarg])
+		escaped = ""
+
 		iarg++
 		i += j + 1
 	}
+
+	if escaped != "" {
+		q.WriteSQL(escaped)
+	}
+}
+
+// expand returns a copy of the writer in which every fragment has been
+// replaced by its rendering in the given dialect.
+func (q *sqlWriter) expand(d Dialect) (sqlWriter, error) {
+	tokens := q.tokens
+	if d == DialectMssql {
+		tokens = topPagination(tokens)
+	}
+
+	var out sqlWriter
+	for _, t := range tokens {
+		if t.frag == nil {
+			out.tokens = append(out.tokens, t)
+			continue
+		}
+
+		var fw sqlWriter
+		if err := t.frag.writeTo(&fw, d); err != nil {
+			return sqlWriter{}, err
+		}
+
+		fw, err := fw.expand(d)
+		if err != nil {
+			return sqlWriter{}, err
+		}
+
+		out.tokens = append(out.tokens, fw.tokens...)
+	}
+	return out, nil
+}
+
+// hasArgs reports whether any token of the writer is an argument.
+func (q *sqlWriter) hasArgs() bool {
+	for _, t := range q.tokens {
+		if t.isArg {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *sqlWriter) mustExpand(d Dialect) sqlWriter {
+	w, err := q.expand(d)
+	if err != nil {
+		panic(err)
+	}
+	return w
 }
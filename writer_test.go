@@ -1,6 +1,8 @@
 package qb
 
 import (
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -16,3 +18,110 @@ func TestWriter(t *testing.T) {
 		})
 	})
 }
+
+func TestWriter_Reuse(t *testing.T) {
+	var base sqlWriter
+	base.WriteSQL("a", "b")
+
+	w1, w2 := base, base
+	w1.WriteSQL("c")
+	w2.WriteSQL("d", "e")
+	base.WriteSQL("f")
+	w1.WriteSQL("g")
+
+	require.Equal(t, []string{"a", "b", "f"}, base.SQL())
+	require.Equal(t, []string{"a", "b", "c", "g"}, w1.SQL())
+	require.Equal(t, []string{"a", "b", "d", "e"}, w2.SQL())
+
+	t.Run("replaced", func(t *testing.T) {
+		w := base
+		w.replaceSQL(0, "x")
+		w3 := w
+		w.WriteSQL("y")
+		w3.WriteSQL("z")
+
+		require.Equal(t, []string{"x", "b", "f", "y"}, w.SQL())
+		require.Equal(t, []string{"x", "b", "f", "z"}, w3.SQL())
+		require.Equal(t, []string{"a", "b", "f"}, base.SQL())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		q := Select("*").From("t")
+
+		var wg sync.WaitGroup
+		qs := make([]Query, 8)
+		for i := range qs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				qs[i] = q.Where(Pred("id = ?", i)).OrderBy("id")
+			}(i)
+		}
+		wg.Wait()
+
+		for i, q := range qs {
+			require.Equal(t, "SELECT * FROM t WHERE id = ? ORDER BY id", q.SQL())
+			require.Equal(t, []interface{}{i}, q.Args())
+		}
+	})
+}
+
+func BenchmarkWriter_ValueTuples(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				q := InsertInto("t", "a", "b", "c")
+				for j := 0; j < n; j++ {
+					q = q.Values(j, "b", true)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriter_Predicate(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var p Predicate
+				for j := 0; j < n; j++ {
+					p = p.And("x = ?", j)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriter_Select(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Select("id", "name").From("users").
+			Where(Pred("age > ?", 18).And("name LIKE ?", "a%")).
+			OrderBy("name").
+			Limit(10)
+	}
+}
+
+func BenchmarkWriter_Render(b *testing.B) {
+	q := WithDialectPQ().Select("id", "name").From("users").
+		Where(Pred("age > ?", 18).And("name LIKE ?", "a%")).
+		OrderBy("name").
+		Limit(10)
+
+	b.Run("SQL", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q.SQL()
+		}
+	})
+
+	b.Run("AppendSQL", func(b *testing.B) {
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf = q.AppendSQL(buf[:0])
+		}
+	})
+}